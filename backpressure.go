@@ -0,0 +1,74 @@
+package langfuse
+
+import (
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/observer"
+)
+
+// BackpressurePolicy 队列满时的背压策略
+type BackpressurePolicy = observer.Policy
+
+const (
+	// BackpressureBlock 阻塞调用方直到队列有空间（默认）
+	BackpressureBlock = observer.PolicyBlock
+	// BackpressureBlockTimeout 最多阻塞 BackpressureConfig.Timeout，超时后丢弃新事件
+	BackpressureBlockTimeout = observer.PolicyBlockTimeout
+	// BackpressureDropNewest 丢弃新事件
+	BackpressureDropNewest = observer.PolicyDropNewest
+	// BackpressureDropOldest 丢弃最旧的排队事件
+	BackpressureDropOldest = observer.PolicyDropOldest
+	// BackpressureSample 队列占用超过 80% 后按 BackpressureConfig.SampleRate 采样，队列满时丢弃新事件
+	BackpressureSample = observer.PolicySample
+)
+
+// BackpressureConfig 队列背压配置
+type BackpressureConfig struct {
+	Policy BackpressurePolicy
+	// Timeout BackpressureBlockTimeout 下的最长阻塞时间
+	Timeout time.Duration
+	// MaxItems 队列最大事件数，0 表示只按字节数限制
+	MaxItems int
+	// SampleRate BackpressureSample 下保留新事件的比例，取值 0~1
+	SampleRate float64
+}
+
+// Stats 事件统计
+type Stats struct {
+	// Sent 发送成功的事件数
+	Sent uint64
+	// Retried 重新入队等待重试的次数
+	Retried uint64
+	// Dropped 发送失败后被丢弃的事件数
+	Dropped uint64
+	// Queued 当前排队的事件数
+	Queued int
+	// QueueDropped 因队列背压被丢弃的事件数
+	QueueDropped uint64
+}
+
+// Stats 返回事件统计，可用于丢失告警
+func (l *Langfuse) Stats() Stats {
+	return Stats{
+		Sent:         l.sent.Load(),
+		Retried:      l.retried.Load(),
+		Dropped:      l.dropped.Load(),
+		Queued:       l.observer.Len(),
+		QueueDropped: l.observer.Dropped(),
+	}
+}
+
+// onQueueDrop 队列丢弃事件时确认 spool 并通知调用方
+func (l *Langfuse) onQueueDrop(item any) {
+	env, ok := item.(envelope)
+	if !ok {
+		return
+	}
+	for _, e := range env.all() {
+		l.ackSpool(e)
+		if l.dropHandler != nil {
+			l.dropHandler(e.event, ErrQueueFull)
+		}
+		e.release()
+	}
+}
@@ -0,0 +1,53 @@
+package langfuse
+
+import (
+	"sync"
+	"time"
+	// 容器等缺少系统时区数据的环境中也能加载时区
+	_ "time/tzdata"
+)
+
+// Clock 时间来源，测试时可替换为固定时间
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 使用系统时间
+type SystemClock struct{}
+
+// Now 当前系统时间
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// normalizeTime 将时间转换到指定时区，返回新的指针，不修改调用方持有的时间
+func normalizeTime(loc *time.Location, times ...**time.Time) {
+	for _, t := range times {
+		if *t == nil {
+			continue
+		}
+		v := (**t).In(loc)
+		*t = &v
+	}
+}
+
+// StepClock 从 start 开始，每次调用 Now 前进 step，用于测试中生成确定的时间
+type StepClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewStepClock 创建一个新的 StepClock，step 为 0 时总是返回 start
+func NewStepClock(start time.Time, step time.Duration) *StepClock {
+	return &StepClock{now: start, step: step}
+}
+
+// Now 返回当前时间并前进一步，并发安全
+func (c *StepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
@@ -0,0 +1,248 @@
+package langfuse
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// coalesce 合并同一发送窗口内仍在队列中、针对同一对象的 create/update 事件，
+// 合并结果保留在首个事件的位置；同一对象的事件按投递序号从旧到新合并，
+// 因此重新入队的旧事件不会覆盖较新的状态。已经离开队列的事件不受影响
+func coalesce(events []envelope) []envelope {
+	if len(events) < 2 {
+		return events
+	}
+
+	index := make(map[string]int)
+	groups := make([][]envelope, 0, len(events))
+	for _, env := range events {
+		key, ok := coalesceKey(env)
+		if !ok {
+			groups = append(groups, []envelope{env})
+			continue
+		}
+		if i, found := index[key]; found {
+			groups[i] = append(groups[i], env)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []envelope{env})
+	}
+
+	out := make([]envelope, 0, len(groups))
+	for _, group := range groups {
+		out = append(out, mergeGroup(group)...)
+	}
+	return out
+}
+
+// mergeGroup 按投递序号从旧到新依次合并同一对象的事件，无法合并的事件单独保留
+func mergeGroup(group []envelope) []envelope {
+	if len(group) == 1 {
+		return group
+	}
+	sort.SliceStable(group, func(i, j int) bool {
+		return group[i].seq < group[j].seq
+	})
+
+	var out []envelope
+	acc := group[0]
+	for _, env := range group[1:] {
+		merged, err := mergeEnvelopes(acc, env)
+		if err != nil {
+			out = append(out, acc)
+			acc = env
+			continue
+		}
+		acc = merged
+	}
+	return append(out, acc)
+}
+
+// coalesceFamilies 可合并的事件类型及其所属对象类别，同一类别同一 ID 的事件会合并
+var coalesceFamilies = map[model.IngestionEventType]string{
+	model.IngestionEventTypeTraceCreate:      "trace",
+	model.IngestionEventTypeSpanCreate:       "span",
+	model.IngestionEventTypeSpanUpdate:       "span",
+	model.IngestionEventTypeGenerationCreate: "generation",
+	model.IngestionEventTypeGenerationUpdate: "generation",
+
+	model.IngestionEventTypeObservationCreate: "observation",
+	model.IngestionEventTypeObservationUpdate: "observation",
+	model.IngestionEventTypeAgentCreate:       "observation",
+	model.IngestionEventTypeToolCreate:        "observation",
+	model.IngestionEventTypeChainCreate:       "observation",
+	model.IngestionEventTypeRetrieverCreate:   "observation",
+	model.IngestionEventTypeEvaluatorCreate:   "observation",
+	model.IngestionEventTypeEmbeddingCreate:   "observation",
+	model.IngestionEventTypeGuardrailCreate:   "observation",
+}
+
+// createEventTypes 合并时优先保留的 create 类型
+var createEventTypes = map[model.IngestionEventType]bool{
+	model.IngestionEventTypeTraceCreate:      true,
+	model.IngestionEventTypeSpanCreate:       true,
+	model.IngestionEventTypeGenerationCreate: true,
+
+	model.IngestionEventTypeObservationCreate: true,
+	model.IngestionEventTypeAgentCreate:       true,
+	model.IngestionEventTypeToolCreate:        true,
+	model.IngestionEventTypeChainCreate:       true,
+	model.IngestionEventTypeRetrieverCreate:   true,
+	model.IngestionEventTypeEvaluatorCreate:   true,
+	model.IngestionEventTypeEmbeddingCreate:   true,
+	model.IngestionEventTypeGuardrailCreate:   true,
+}
+
+// coalesceKey 返回可合并事件的键，不可合并时返回 false
+func coalesceKey(env envelope) (string, bool) {
+	family, ok := coalesceFamilies[env.event.Type]
+	if !ok {
+		return "", false
+	}
+	id := bodyID(env)
+	if id == "" {
+		return "", false
+	}
+	return family + ":" + id, true
+}
+
+// bodyID 获取事件 body 中的对象 ID
+func bodyID(env envelope) string {
+	switch body := env.event.Body.(type) {
+	case *model.Trace:
+		return body.ID
+	case *model.Span:
+		return body.ID
+	case *model.Generation:
+		return body.ID
+	case observable:
+		return body.Base().ID
+	}
+
+	var event struct {
+		Body struct {
+			ID string `json:"id"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(env.raw, &event); err != nil {
+		return ""
+	}
+	return event.Body.ID
+}
+
+// mergeEnvelopes 将 next 合并到 base：next 中出现的字段覆盖 base，tags 取并集，metadata 按键合并
+func mergeEnvelopes(base, next envelope) (envelope, error) {
+	var baseEvent, nextEvent map[string]json.RawMessage
+	if err := json.Unmarshal(base.raw, &baseEvent); err != nil {
+		return base, err
+	}
+	if err := json.Unmarshal(next.raw, &nextEvent); err != nil {
+		return base, err
+	}
+
+	body, err := mergeBody(baseEvent["body"], nextEvent["body"])
+	if err != nil {
+		return base, err
+	}
+	baseEvent["body"] = body
+	baseEvent["timestamp"] = nextEvent["timestamp"]
+	eventType := base.event.Type
+	if createEventTypes[next.event.Type] {
+		// create 与 update 合并为携带最终状态的 create
+		eventType = next.event.Type
+		baseEvent["type"] = nextEvent["type"]
+	}
+
+	raw, err := json.Marshal(baseEvent)
+	if err != nil {
+		return base, err
+	}
+
+	merged := base
+	merged.raw = raw
+	merged.event.Body = body
+	merged.event.Timestamp = next.event.Timestamp
+	merged.event.Type = eventType
+	merged.seq = next.seq
+	merged.merged = append(append([]envelope{}, base.merged...), next)
+	return merged, nil
+}
+
+// mergeBody 合并两个 JSON 对象
+func mergeBody(base, next json.RawMessage) (json.RawMessage, error) {
+	var baseBody, nextBody map[string]json.RawMessage
+	if err := json.Unmarshal(base, &baseBody); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(next, &nextBody); err != nil {
+		return nil, err
+	}
+	if baseBody == nil {
+		baseBody = make(map[string]json.RawMessage, len(nextBody))
+	}
+
+	for key, value := range nextBody {
+		if _, ok := baseBody[key]; ok && emptyJSON(value) {
+			// 部分更新中未设置的字段（如非指针的 usage 编码为 {}）不覆盖已有的值
+			continue
+		}
+		switch key {
+		case "tags":
+			baseBody[key] = unionTags(baseBody[key], value)
+		case "metadata":
+			baseBody[key] = mergeObject(baseBody[key], value)
+		default:
+			baseBody[key] = value
+		}
+	}
+	return json.Marshal(baseBody)
+}
+
+// emptyJSON 判断值是否为 null 或空对象
+func emptyJSON(value json.RawMessage) bool {
+	value = bytes.TrimSpace(value)
+	return bytes.Equal(value, []byte("null")) || bytes.Equal(value, []byte("{}"))
+}
+
+// unionTags 合并两个字符串数组并去重，解析失败时使用 next
+func unionTags(base, next json.RawMessage) json.RawMessage {
+	var baseTags, nextTags []string
+	if json.Unmarshal(base, &baseTags) != nil || json.Unmarshal(next, &nextTags) != nil {
+		return next
+	}
+
+	seen := make(map[string]struct{}, len(baseTags)+len(nextTags))
+	tags := make([]string, 0, len(baseTags)+len(nextTags))
+	for _, tag := range append(baseTags, nextTags...) {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	raw, err := json.Marshal(tags)
+	if err != nil {
+		return next
+	}
+	return raw
+}
+
+// mergeObject 浅合并两个 JSON 对象，任一方不是对象时使用 next
+func mergeObject(base, next json.RawMessage) json.RawMessage {
+	var baseObj, nextObj map[string]json.RawMessage
+	if json.Unmarshal(base, &baseObj) != nil || json.Unmarshal(next, &nextObj) != nil || baseObj == nil || nextObj == nil {
+		return next
+	}
+	for key, value := range nextObj {
+		baseObj[key] = value
+	}
+	raw, err := json.Marshal(baseObj)
+	if err != nil {
+		return next
+	}
+	return raw
+}
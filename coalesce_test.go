@@ -0,0 +1,128 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+func testEnvelope(t *testing.T, seq uint64, eventType model.IngestionEventType, body any) envelope {
+	t.Helper()
+	env, err := encodeEvent(model.IngestionEvent{Type: eventType, ID: "event", Body: body})
+	if err != nil {
+		t.Fatalf("encode event: %v", err)
+	}
+	env.seq = seq
+	return env
+}
+
+// decodeBody 解码合并后事件的 body，便于按字段比较
+func decodeBody(t *testing.T, env envelope) map[string]any {
+	t.Helper()
+	var event struct {
+		Body map[string]any `json:"body"`
+	}
+	if err := json.Unmarshal(env.raw, &event); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	return event.Body
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   func(t *testing.T) []envelope
+		wantLen  int
+		wantType model.IngestionEventType
+		wantBody map[string]any
+	}{
+		{
+			name: "partial update keeps usage from create",
+			events: func(t *testing.T) []envelope {
+				return []envelope{
+					testEnvelope(t, 1, model.IngestionEventTypeGenerationCreate, &model.Generation{
+						ID: "g1", Name: "chat", Usage: model.Usage{Input: 10, Output: 5},
+					}),
+					testEnvelope(t, 2, model.IngestionEventTypeGenerationUpdate, &model.Generation{
+						ID: "g1", Output: "done",
+					}),
+				}
+			},
+			wantLen:  1,
+			wantType: model.IngestionEventTypeGenerationCreate,
+			wantBody: map[string]any{
+				"id":     "g1",
+				"name":   "chat",
+				"output": "done",
+				"usage":  map[string]any{"input": float64(10), "output": float64(5)},
+			},
+		},
+		{
+			name: "requeued older update does not overwrite newer state",
+			events: func(t *testing.T) []envelope {
+				return []envelope{
+					testEnvelope(t, 3, model.IngestionEventTypeSpanUpdate, &model.Span{ID: "s1", Name: "newest"}),
+					testEnvelope(t, 1, model.IngestionEventTypeSpanCreate, &model.Span{ID: "s1", Name: "oldest", Input: "in"}),
+					testEnvelope(t, 2, model.IngestionEventTypeSpanUpdate, &model.Span{ID: "s1", Name: "middle"}),
+				}
+			},
+			wantLen:  1,
+			wantType: model.IngestionEventTypeSpanCreate,
+			wantBody: map[string]any{"id": "s1", "name": "newest", "input": "in"},
+		},
+		{
+			name: "tags are unioned and metadata is merged by key",
+			events: func(t *testing.T) []envelope {
+				return []envelope{
+					testEnvelope(t, 1, model.IngestionEventTypeTraceCreate, &model.Trace{
+						ID: "t1", Tags: []string{"a", "b"}, Metadata: model.M{"k1": "v1", "k2": "old"},
+					}),
+					testEnvelope(t, 2, model.IngestionEventTypeTraceCreate, &model.Trace{
+						ID: "t1", Tags: []string{"b", "c"}, Metadata: model.M{"k2": "new"},
+					}),
+				}
+			},
+			wantLen:  1,
+			wantType: model.IngestionEventTypeTraceCreate,
+			wantBody: map[string]any{
+				"id":       "t1",
+				"tags":     []any{"a", "b", "c"},
+				"metadata": map[string]any{"k1": "v1", "k2": "new"},
+			},
+		},
+		{
+			name: "different objects are not merged",
+			events: func(t *testing.T) []envelope {
+				return []envelope{
+					testEnvelope(t, 1, model.IngestionEventTypeSpanCreate, &model.Span{ID: "s1"}),
+					testEnvelope(t, 2, model.IngestionEventTypeGenerationCreate, &model.Generation{ID: "s1"}),
+					testEnvelope(t, 3, model.IngestionEventTypeScoreCreate, &model.Score{ID: "s1", Name: "quality"}),
+				}
+			},
+			wantLen: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := tt.events(t)
+			out := coalesce(events)
+			if len(out) != tt.wantLen {
+				t.Fatalf("got %d events, want %d", len(out), tt.wantLen)
+			}
+			if tt.wantBody == nil {
+				return
+			}
+			if got := out[0].event.Type; got != tt.wantType {
+				t.Errorf("type = %s, want %s", got, tt.wantType)
+			}
+			if got := decodeBody(t, out[0]); !reflect.DeepEqual(got, tt.wantBody) {
+				t.Errorf("body = %v, want %v", got, tt.wantBody)
+			}
+			if got := len(out[0].all()); got != len(events) {
+				t.Errorf("merged event expands to %d originals, want %d", got, len(events))
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package langfuse
+
+import (
+	"context"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+type spanContextKey struct{}
+
+type promptContextKey struct{}
+
+// SpanContext 上下文中携带的当前 trace 与 observation
+type SpanContext struct {
+	TraceID       string
+	ObservationID string
+}
+
+// ContextWithSpanContext 返回携带 sc 的新上下文
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// FromContext 获取上下文中的当前 trace 与 observation
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// TraceIDFromContext 获取上下文中的当前 trace ID，不存在时返回空字符串
+func TraceIDFromContext(ctx context.Context) string {
+	sc, _ := FromContext(ctx)
+	return sc.TraceID
+}
+
+// ObservationIDFromContext 获取上下文中的当前 observation ID，不存在时返回空字符串
+func ObservationIDFromContext(ctx context.Context) string {
+	sc, _ := FromContext(ctx)
+	return sc.ObservationID
+}
+
+// ContextWithPrompt 返回携带 prompt 的新上下文，在其下通过 StartGeneration 创建的 generation 会关联该 prompt
+func ContextWithPrompt(ctx context.Context, prompt model.Prompt) context.Context {
+	return context.WithValue(ctx, promptContextKey{}, prompt)
+}
+
+// PromptFromContext 获取上下文中的 prompt
+func PromptFromContext(ctx context.Context) (model.Prompt, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	prompt, ok := ctx.Value(promptContextKey{}).(model.Prompt)
+	return prompt, ok && prompt != nil
+}
+
+// StartTrace 创建 trace 句柄并返回携带该 trace 的上下文
+func (l *Langfuse) StartTrace(ctx context.Context, t *model.Trace) (context.Context, *Trace, error) {
+	t, err := l.Trace(t)
+	if err != nil {
+		return ctx, nil, err
+	}
+	trace := &Trace{l: l, body: t}
+	return trace.Context(ctx), trace, nil
+}
+
+// StartSpan 创建 span 句柄，未设置的 TraceID 与 ParentObservationID 取自上下文，返回以该 span 为父节点的上下文
+func (l *Langfuse) StartSpan(ctx context.Context, s *model.Span) (context.Context, *Span, error) {
+	inheritContext(ctx, &s.TraceID, &s.ParentObservationID)
+	span, err := l.newSpan(s)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return span.Context(ctx), span, nil
+}
+
+// StartGeneration 创建 generation 句柄，未设置的 TraceID、ParentObservationID 与 Prompt 取自上下文，返回以该 generation 为父节点的上下文
+func (l *Langfuse) StartGeneration(ctx context.Context, g *model.Generation) (context.Context, *Generation, error) {
+	inheritContext(ctx, &g.TraceID, &g.ParentObservationID)
+	if g.Prompt == nil && g.PromptName == "" {
+		g.Prompt, _ = PromptFromContext(ctx)
+	}
+	generation, err := l.newGeneration(g)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return generation.Context(ctx), generation, nil
+}
+
+// inheritContext 用上下文中的 trace 与 observation 填充未设置的字段，显式指定了其他 trace 时不继承父节点
+func inheritContext(ctx context.Context, traceID, parentObservationID *string) {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	if *traceID == "" {
+		*traceID = sc.TraceID
+	}
+	if *parentObservationID == "" && *traceID == sc.TraceID {
+		*parentObservationID = sc.ObservationID
+	}
+}
@@ -0,0 +1,102 @@
+package langfuse
+
+import (
+	"os"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+const (
+	envTracingEnvironment = "LANGFUSE_TRACING_ENVIRONMENT"
+	envRelease            = "LANGFUSE_RELEASE"
+)
+
+// defaults 客户端级别的默认字段，只在创建 trace 与 observation 时合并到副本，部分更新不合并；调用方设置的值优先
+type defaults struct {
+	environment string
+	release     string
+	version     string
+	tags        []string
+	metadata    map[string]any
+}
+
+// withEnv 未设置的 environment 与 release 回退到环境变量
+func (d defaults) withEnv() defaults {
+	if d.environment == "" {
+		d.environment = os.Getenv(envTracingEnvironment)
+	}
+	if d.release == "" {
+		d.release = os.Getenv(envRelease)
+	}
+	return d
+}
+
+// applyTrace 合并 trace 的默认字段
+func (d defaults) applyTrace(t *model.Trace) {
+	setDefault(&t.Environment, d.environment)
+	setDefault(&t.Release, d.release)
+	setDefault(&t.Version, d.version)
+	t.Tags = d.mergeTags(t.Tags)
+	t.Metadata = d.mergeMetadata(t.Metadata)
+}
+
+// applyObservation observation 没有 release 与 tags，只合并 environment、version 与 metadata
+func (d defaults) applyObservation(environment, version *string, metadata *any) {
+	setDefault(environment, d.environment)
+	setDefault(version, d.version)
+	*metadata = d.mergeMetadata(*metadata)
+}
+
+// mergeTags 默认标签在前，去重后追加调用方的标签
+func (d defaults) mergeTags(tags []string) []string {
+	if len(d.tags) == 0 {
+		return tags
+	}
+	seen := make(map[string]struct{}, len(d.tags)+len(tags))
+	merged := make([]string, 0, len(d.tags)+len(tags))
+	for _, tag := range append(append([]string(nil), d.tags...), tags...) {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+// mergeMetadata 调用方的键覆盖默认值，metadata 不是对象时无法合并，保留调用方的值
+func (d defaults) mergeMetadata(metadata any) any {
+	if len(d.metadata) == 0 {
+		return metadata
+	}
+	var own map[string]any
+	switch m := metadata.(type) {
+	case nil:
+	case model.M:
+		own = m
+	case map[string]any:
+		own = m
+	case map[string]string:
+		own = make(map[string]any, len(m))
+		for k, v := range m {
+			own[k] = v
+		}
+	default:
+		return metadata
+	}
+
+	merged := make(model.M, len(d.metadata)+len(own))
+	for k, v := range d.metadata {
+		merged[k] = v
+	}
+	for k, v := range own {
+		merged[k] = v
+	}
+	return merged
+}
+
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
@@ -0,0 +1,178 @@
+package langfuse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/observer"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// maxPooledBufferSize 超过该大小的缓冲区不放回池中，避免长期占用内存
+const maxPooledBufferSize = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// envelope 队列中的事件及其客户端附加信息
+type envelope struct {
+	event   model.IngestionEvent
+	raw     []byte        // 事件的 JSON 编码，dispatch 时生成一次
+	buf     *bytes.Buffer // raw 所在的池化缓冲区，为空表示 raw 不来自池
+	segment uint64        // 所在 spool 段，0 表示未落盘
+	merged  []envelope    // 合并到该事件中的其他事件，随该事件一起确认
+	seq     uint64        // 投递序号，合并时序号大的事件为较新的状态
+	// notBefore 重试事件的最早发送时间，未到时间的事件留在队列中等待下次发送
+	notBefore time.Time
+}
+
+// encodeEvent 将事件编码到池化缓冲区
+func encodeEvent(event model.IngestionEvent) (envelope, error) {
+	buf, _ := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event); err != nil {
+		bufferPool.Put(buf)
+		return envelope{}, err
+	}
+	// 去掉 Encoder 追加的换行符
+	raw := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return envelope{event: event, raw: raw, buf: buf}, nil
+}
+
+// Size 事件编码后的大小，供队列和批量发送统计
+func (e envelope) Size() int {
+	return len(e.raw)
+}
+
+// all 返回合并前的所有原始事件
+func (e envelope) all() []envelope {
+	if len(e.merged) == 0 {
+		return []envelope{e}
+	}
+	self := e
+	self.merged = nil
+	out := []envelope{self}
+	for _, m := range e.merged {
+		out = append(out, m.all()...)
+	}
+	return out
+}
+
+// release 事件处理完成（发送成功或最终丢弃）后归还缓冲区，合并的事件需先通过 all 展开
+func (e envelope) release() {
+	if e.buf == nil || e.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	e.buf.Reset()
+	bufferPool.Put(e.buf)
+}
+
+// bodyDefaults 决定 dispatch 是否为 body 合并客户端默认字段
+type bodyDefaults bool
+
+const (
+	// applyDefaults 创建对象的事件，合并默认字段
+	applyDefaults bodyDefaults = true
+	// skipDefaults 部分更新的事件，只发送调用方设置的字段，避免覆盖创建时的值
+	skipDefaults bodyDefaults = false
+)
+
+// dispatch 整理 body、编码事件并写入 spool（如已开启）后投递到观察者队列，
+// Shutdown 之后或创建时的 ctx 结束（发送协程已退出）后返回 ErrClosed；因背压丢弃的事件由 DropHandler 报告
+func (l *Langfuse) dispatch(event model.IngestionEvent, mode bodyDefaults) error {
+	event.Timestamp = event.Timestamp.In(l.location)
+	event.Body = l.prepareBody(event.Body, mode)
+	env, err := encodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	l.closeMu.RLock()
+	defer l.closeMu.RUnlock()
+	if l.closed {
+		env.release()
+		return ErrClosed
+	}
+	env.seq = l.seq.Add(1)
+	l.persist(&env)
+	if err = l.observer.Dispatch(env); errors.Is(err, observer.ErrClosed) {
+		l.ackSpool(env)
+		env.release()
+		return ErrClosed
+	}
+	return nil
+}
+
+// prepareBody 复制 body 后合并默认字段（仅 applyDefaults）并将时间统一到配置的时区，不修改调用方持有的对象；score 等其他类型原样返回
+func (l *Langfuse) prepareBody(body any, mode bodyDefaults) any {
+	d := defaults{}
+	if mode == applyDefaults {
+		d = l.defaults
+	}
+	loc := l.location
+	switch b := body.(type) {
+	case *model.Trace:
+		t := *b
+		d.applyTrace(&t)
+		normalizeTime(loc, &t.Timestamp)
+		return &t
+	case *model.Span:
+		s := *b
+		d.applyObservation(&s.Environment, &s.Version, &s.Metadata)
+		normalizeTime(loc, &s.StartTime, &s.EndTime)
+		return &s
+	case *model.Generation:
+		g := *b
+		d.applyObservation(&g.Environment, &g.Version, &g.Metadata)
+		normalizeTime(loc, &g.StartTime, &g.EndTime, &g.CompletionStartTime)
+		return &g
+	case *model.Event:
+		e := *b
+		d.applyObservation(&e.Environment, &e.Version, &e.Metadata)
+		normalizeTime(loc, &e.StartTime)
+		return &e
+	case *model.Observation:
+		o := *b
+		prepareObservation(d, loc, &o.ObservationBody)
+		normalizeTime(loc, &o.CompletionStartTime)
+		return &o
+	case *model.Agent:
+		return prepareObservable(d, loc, b)
+	case *model.Tool:
+		return prepareObservable(d, loc, b)
+	case *model.Chain:
+		return prepareObservable(d, loc, b)
+	case *model.Retriever:
+		return prepareObservable(d, loc, b)
+	case *model.Evaluator:
+		return prepareObservable(d, loc, b)
+	case *model.Embedding:
+		return prepareObservable(d, loc, b)
+	case *model.Guardrail:
+		return prepareObservable(d, loc, b)
+	}
+	return body
+}
+
+// prepareObservable 复制 observation 并整理通用字段
+func prepareObservable[T any, PT interface {
+	*T
+	observable
+}](d defaults, loc *time.Location, body PT) any {
+	c := *body
+	prepareObservation(d, loc, PT(&c).Base())
+	return PT(&c)
+}
+
+func prepareObservation(d defaults, loc *time.Location, base *model.ObservationBody) {
+	d.applyObservation(&base.Environment, &base.Version, &base.Metadata)
+	normalizeTime(loc, &base.StartTime, &base.EndTime)
+}
@@ -0,0 +1,95 @@
+package langfuse
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// benchmarkBatchSize 与默认批量大小接近的单批事件数
+const benchmarkBatchSize = 100
+
+func benchmarkEvents() []model.IngestionEvent {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := make([]model.IngestionEvent, 0, benchmarkBatchSize)
+	for i := 0; i < benchmarkBatchSize; i++ {
+		id := "generation-" + strconv.Itoa(i)
+		end := now.Add(time.Second)
+		events = append(events, model.IngestionEvent{
+			Type:      model.IngestionEventTypeGenerationCreate,
+			ID:        "event-" + strconv.Itoa(i),
+			Timestamp: now,
+			Body: &model.Generation{
+				ID:        id,
+				TraceID:   "trace-" + strconv.Itoa(i/10),
+				Name:      "chat",
+				StartTime: &now,
+				EndTime:   &end,
+				Model:     "gpt-4o",
+				Input: []model.M{
+					{"role": "system", "content": "You are a helpful assistant."},
+					{"role": "user", "content": "Summarize the following document in three sentences."},
+				},
+				Output:   model.M{"role": "assistant", "content": "The document describes the ingestion pipeline."},
+				Metadata: model.M{"tenant": "bench", "attempt": i},
+			},
+		})
+	}
+	return events
+}
+
+// BenchmarkBatchMarshal 逐个 json.Marshal 统计大小，发送时再整体 json.Marshal 请求体
+func BenchmarkBatchMarshal(b *testing.B) {
+	events := benchmarkEvents()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		size := 0
+		for _, event := range events {
+			raw, err := json.Marshal(event)
+			if err != nil {
+				b.Fatal(err)
+			}
+			size += len(raw)
+		}
+		ingestion := &api.Ingestion{Batch: events}
+		if _, err := ingestion.Encode(); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(size))
+	}
+}
+
+// BenchmarkBatchRaw dispatch 时编码一次，发送时直接拼接 envelope.raw
+func BenchmarkBatchRaw(b *testing.B) {
+	events := benchmarkEvents()
+	envs := make([]envelope, 0, len(events))
+	raw := make([]json.RawMessage, 0, len(events))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		envs, raw = envs[:0], raw[:0]
+		size := 0
+		for _, event := range events {
+			env, err := encodeEvent(event)
+			if err != nil {
+				b.Fatal(err)
+			}
+			envs = append(envs, env)
+			raw = append(raw, env.raw)
+			size += env.Size()
+		}
+		ingestion := &api.Ingestion{RawBatch: raw}
+		if _, err := ingestion.Encode(); err != nil {
+			b.Fatal(err)
+		}
+		for _, env := range envs {
+			env.release()
+		}
+		b.SetBytes(int64(size))
+	}
+}
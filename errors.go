@@ -0,0 +1,49 @@
+package langfuse
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// ErrClosed Langfuse 已关闭
+var ErrClosed = errors.New("langfuse: client is closed")
+
+// ErrQueueFull 事件因队列背压被丢弃
+var ErrQueueFull = errors.New("langfuse: event queue is full")
+
+// IngestionError 服务端针对单个事件返回的错误
+type IngestionError struct {
+	EventID string
+	Status  int
+	Message string
+}
+
+func (e *IngestionError) Error() string {
+	return fmt.Sprintf("langfuse: event %s rejected with status %d: %s", e.EventID, e.Status, e.Message)
+}
+
+// ShutdownError Shutdown 未能发送全部事件：ctx 在发送完成前结束，或运行期间有事件被丢弃
+type ShutdownError struct {
+	// Stats 关闭时的事件统计，Queued 为未发送的事件数
+	Stats Stats
+	// Err ctx 在发送完成前结束时为 ctx.Err()，否则为 nil
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	msg := fmt.Sprintf("langfuse: shutdown with %d unsent and %d dropped events",
+		e.Stats.Queued, e.Stats.Dropped+e.Stats.QueueDropped)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// DropHandler 事件被最终丢弃时的回调，err 为导致丢弃的原因
+type DropHandler func(event model.IngestionEvent, err error)
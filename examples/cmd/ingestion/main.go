@@ -8,7 +8,7 @@ import (
 )
 
 func main() {
-	l := langfuse.New(context.Background())
+	l := langfuse.NewWithOptions(context.Background())
 
 	trace, err := l.Trace(&model.Trace{Name: "test-trace"})
 	if err != nil {
@@ -93,6 +93,7 @@ func main() {
 		panic(err)
 	}
 
-	l.Flush(context.Background())
-
+	if err = l.Shutdown(context.Background()); err != nil {
+		panic(err)
+	}
 }
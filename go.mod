@@ -5,6 +5,7 @@ go 1.21.1
 require (
 	github.com/google/uuid v1.6.0
 	github.com/henomis/restclientgo v1.2.0
+	github.com/klauspost/compress v1.17.11
 	github.com/sirupsen/logrus v1.9.3
 )
 
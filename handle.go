@@ -0,0 +1,431 @@
+package langfuse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// EndOption 结束 observation 时的配置项
+type EndOption func(*endOptions)
+
+type endOptions struct {
+	endTime   time.Time
+	output    any
+	hasOutput bool
+}
+
+// WithEndTime 指定结束时间，默认使用调用 End 时的时间
+func WithEndTime(t time.Time) EndOption {
+	return func(o *endOptions) {
+		o.endTime = t
+	}
+}
+
+// WithEndOutput 结束时设置输出
+func WithEndOutput(output any) EndOption {
+	return func(o *endOptions) {
+		o.output = output
+		o.hasOutput = true
+	}
+}
+
+func (l *Langfuse) endOptions(opts []EndOption) endOptions {
+	o := endOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.endTime.IsZero() {
+		o.endTime = l.now()
+	}
+	return o
+}
+
+// Trace trace 句柄，方法可并发调用，End 只有第一次生效，可直接用于 defer
+type Trace struct {
+	l     *Langfuse
+	mu    sync.Mutex
+	body  *model.Trace
+	ended bool
+}
+
+// ID trace ID，句柄为 nil 时返回空字符串
+func (t *Trace) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.body.ID
+}
+
+// Context 返回以该 trace 为当前 trace 的上下文
+func (t *Trace) Context(ctx context.Context) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, SpanContext{TraceID: t.body.ID})
+}
+
+// Update 只发送 fn 设置的字段，未设置的字段在服务端保持不变；
+// fn 会分别作用于增量和本地状态，应只做字段赋值
+func (t *Trace) Update(fn func(t *model.Trace)) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	patch := &model.Trace{}
+	fn(patch)
+	fn(t.body)
+	patch.ID = t.body.ID
+	_, err := t.l.TraceUpdate(patch)
+	return err
+}
+
+// SetOutput 设置 trace 输出，随 End 一起发送
+func (t *Trace) SetOutput(output any) *Trace {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.body.Output = output
+	return t
+}
+
+// End 发送 trace 的最终状态，trace 没有结束时间，重复调用直接返回
+func (t *Trace) End(opts ...EndOption) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return nil
+	}
+	t.ended = true
+
+	o := t.l.endOptions(opts)
+	if o.hasOutput {
+		t.body.Output = o.output
+	}
+	_, err := t.l.Trace(t.body)
+	return err
+}
+
+// Span 在 trace 下创建 span
+func (t *Trace) Span(s *model.Span) (*Span, error) {
+	if t == nil {
+		return nil, ErrClosed
+	}
+	s.TraceID = t.body.ID
+	return t.l.newSpan(s)
+}
+
+// Generation 在 trace 下创建 generation
+func (t *Trace) Generation(g *model.Generation) (*Generation, error) {
+	if t == nil {
+		return nil, ErrClosed
+	}
+	g.TraceID = t.body.ID
+	return t.l.newGeneration(g)
+}
+
+// Span span 句柄，方法可并发调用，End 只有第一次生效，可直接用于 defer
+type Span struct {
+	l     *Langfuse
+	mu    sync.Mutex
+	body  *model.Span
+	ended bool
+}
+
+// newSpan 创建 span 并在客户端记录开始时间
+func (l *Langfuse) newSpan(s *model.Span) (*Span, error) {
+	if s.StartTime == nil {
+		now := l.now()
+		s.StartTime = &now
+	}
+	s, err := l.Span(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Span{l: l, body: s}, nil
+}
+
+// ID span ID，句柄为 nil 时返回空字符串
+func (s *Span) ID() string {
+	if s == nil {
+		return ""
+	}
+	return s.body.ID
+}
+
+// TraceID 所属 trace ID
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.body.TraceID
+}
+
+// Context 返回以该 span 为父节点的上下文
+func (s *Span) Context(ctx context.Context) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, SpanContext{TraceID: s.body.TraceID, ObservationID: s.body.ID})
+}
+
+// Update 修改 span 并立即发送 span-update
+func (s *Span) Update(fn func(s *model.Span)) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.body)
+	_, err := s.l.updateSpan(s.body)
+	return err
+}
+
+// SetOutput 设置输出，随 End 一起发送
+func (s *Span) SetOutput(output any) *Span {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body.Output = output
+	return s
+}
+
+// SetLevel 设置级别，随 End 一起发送
+func (s *Span) SetLevel(level model.ObservationLevel) *Span {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body.Level = level
+	return s
+}
+
+// RecordError 记录错误，级别设为 ERROR，随 End 一起发送，err 为 nil 时忽略
+func (s *Span) RecordError(err error) *Span {
+	if s == nil || err == nil {
+		return s
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body.Level = model.ObservationLevelError
+	s.body.StatusMessage = err.Error()
+	return s
+}
+
+// End 记录结束时间并发送 span-update，重复调用直接返回
+func (s *Span) End(opts ...EndOption) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil
+	}
+	s.ended = true
+
+	o := s.l.endOptions(opts)
+	if o.hasOutput {
+		s.body.Output = o.output
+	}
+	s.body.EndTime = &o.endTime
+	_, err := s.l.updateSpan(s.body)
+	return err
+}
+
+// Child 创建子 span
+func (s *Span) Child(child *model.Span) (*Span, error) {
+	if s == nil {
+		return nil, ErrClosed
+	}
+	child.TraceID = s.body.TraceID
+	child.ParentObservationID = s.body.ID
+	return s.l.newSpan(child)
+}
+
+// ChildGeneration 创建子 generation
+func (s *Span) ChildGeneration(child *model.Generation) (*Generation, error) {
+	if s == nil {
+		return nil, ErrClosed
+	}
+	child.TraceID = s.body.TraceID
+	child.ParentObservationID = s.body.ID
+	return s.l.newGeneration(child)
+}
+
+// Generation generation 句柄，方法可并发调用，End 只有第一次生效，可直接用于 defer
+type Generation struct {
+	l     *Langfuse
+	mu    sync.Mutex
+	body  *model.Generation
+	ended bool
+}
+
+// newGeneration 创建 generation 并在客户端记录开始时间
+func (l *Langfuse) newGeneration(g *model.Generation) (*Generation, error) {
+	if g.StartTime == nil {
+		now := l.now()
+		g.StartTime = &now
+	}
+	g, err := l.Generation(g, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Generation{l: l, body: g}, nil
+}
+
+// ID generation ID，句柄为 nil 时返回空字符串
+func (g *Generation) ID() string {
+	if g == nil {
+		return ""
+	}
+	return g.body.ID
+}
+
+// TraceID 所属 trace ID
+func (g *Generation) TraceID() string {
+	if g == nil {
+		return ""
+	}
+	return g.body.TraceID
+}
+
+// Context 返回以该 generation 为父节点的上下文
+func (g *Generation) Context(ctx context.Context) context.Context {
+	if g == nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, SpanContext{TraceID: g.body.TraceID, ObservationID: g.body.ID})
+}
+
+// Update 修改 generation 并立即发送 generation-update
+func (g *Generation) Update(fn func(g *model.Generation)) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fn(g.body)
+	_, err := g.l.updateGeneration(g.body)
+	return err
+}
+
+// SetOutput 设置输出，随 End 一起发送
+func (g *Generation) SetOutput(output any) *Generation {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.Output = output
+	return g
+}
+
+// SetLevel 设置级别，随 End 一起发送
+func (g *Generation) SetLevel(level model.ObservationLevel) *Generation {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.Level = level
+	return g
+}
+
+// SetUsage 设置用量，随 End 一起发送
+func (g *Generation) SetUsage(usage model.Usage) *Generation {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.Usage = usage
+	return g
+}
+
+// SetUsageDetails 设置用量明细，随 End 一起发送
+func (g *Generation) SetUsageDetails(details model.UsageDetails) *Generation {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.UsageDetails = details
+	return g
+}
+
+// SetCostDetails 设置费用明细，随 End 一起发送
+func (g *Generation) SetCostDetails(details model.CostDetails) *Generation {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.CostDetails = details
+	return g
+}
+
+// SetCompletionStartTime 记录首个 token 返回的时间
+func (g *Generation) SetCompletionStartTime(t time.Time) *Generation {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.CompletionStartTime = &t
+	return g
+}
+
+// RecordError 记录错误，级别设为 ERROR，随 End 一起发送，err 为 nil 时忽略
+func (g *Generation) RecordError(err error) *Generation {
+	if g == nil || err == nil {
+		return g
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.body.Level = model.ObservationLevelError
+	g.body.StatusMessage = err.Error()
+	return g
+}
+
+// End 记录结束时间并发送 generation-update，重复调用直接返回
+func (g *Generation) End(opts ...EndOption) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.ended {
+		return nil
+	}
+	g.ended = true
+
+	o := g.l.endOptions(opts)
+	if o.hasOutput {
+		g.body.Output = o.output
+	}
+	g.body.EndTime = &o.endTime
+	_, err := g.l.updateGeneration(g.body)
+	return err
+}
+
+// Child 创建子 span
+func (g *Generation) Child(child *model.Span) (*Span, error) {
+	if g == nil {
+		return nil, ErrClosed
+	}
+	child.TraceID = g.body.TraceID
+	child.ParentObservationID = g.body.ID
+	return g.l.newSpan(child)
+}
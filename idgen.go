@@ -0,0 +1,68 @@
+package langfuse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator ID 生成器，测试时可替换为确定的实现
+type IDGenerator interface {
+	NewID() string
+}
+
+// UUIDv4Generator 随机 UUID，默认实现
+type UUIDv4Generator struct{}
+
+// NewID 生成 UUIDv4
+func (UUIDv4Generator) NewID() string {
+	return uuid.NewString()
+}
+
+// UUIDv7Generator 按时间有序的 UUID，便于按 ID 排序与索引
+type UUIDv7Generator struct{}
+
+// NewID 生成 UUIDv7，失败时回退到 UUIDv4
+func (UUIDv7Generator) NewID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// W3CTraceIDGenerator 兼容 W3C Trace Context 的 32 位小写十六进制 trace ID，可与 OpenTelemetry 的 trace 关联
+type W3CTraceIDGenerator struct{}
+
+// NewID 生成非全零的 16 字节随机 ID
+func (W3CTraceIDGenerator) NewID() string {
+	var id [16]byte
+	for {
+		if _, err := rand.Read(id[:]); err != nil {
+			// 随机源不可用时使用 UUIDv4 的字节
+			id = uuid.New()
+		}
+		if id != [16]byte{} {
+			return hex.EncodeToString(id[:])
+		}
+	}
+}
+
+// SequentialIDGenerator 按顺序生成 prefix1、prefix2……，用于测试中生成确定的 ID
+type SequentialIDGenerator struct {
+	prefix string
+	next   atomic.Uint64
+}
+
+// NewSequentialIDGenerator 创建一个新的 SequentialIDGenerator
+func NewSequentialIDGenerator(prefix string) *SequentialIDGenerator {
+	return &SequentialIDGenerator{prefix: prefix}
+}
+
+// NewID 生成下一个 ID，并发安全
+func (g *SequentialIDGenerator) NewID() string {
+	return g.prefix + strconv.FormatUint(g.next.Add(1), 10)
+}
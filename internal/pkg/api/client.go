@@ -5,8 +5,10 @@ import (
 	"encoding/base64"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/henomis/restclientgo"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
 )
 
 const (
@@ -17,18 +19,53 @@ type Client struct {
 	restClient *restclientgo.RestClient
 }
 
+// Config 客户端配置，空字段回退到环境变量
+type Config struct {
+	Host       string
+	PublicKey  string
+	SecretKey  string
+	HTTPClient *http.Client
+	// Compression 上报请求体的压缩方式（EncodingGzip、EncodingZstd），为空时不压缩
+	Compression string
+	// CompressionThreshold 请求体小于该字节数时不压缩
+	CompressionThreshold int
+}
+
 func New() *Client {
-	langfuseHost := os.Getenv("LANGFUSE_HOST")
+	return NewWithConfig(Config{})
+}
+
+func NewWithConfig(cfg Config) *Client {
+	langfuseHost := cfg.Host
+	if langfuseHost == "" {
+		langfuseHost = os.Getenv("LANGFUSE_HOST")
+	}
 	if langfuseHost == "" {
 		langfuseHost = langfuseDefaultEndpoint
 	}
 
-	publicKey := os.Getenv("LANGFUSE_PUBLIC_KEY")
-	secretKey := os.Getenv("LANGFUSE_SECRET_KEY")
+	publicKey := cfg.PublicKey
+	if publicKey == "" {
+		publicKey = os.Getenv("LANGFUSE_PUBLIC_KEY")
+	}
+	secretKey := cfg.SecretKey
+	if secretKey == "" {
+		secretKey = os.Getenv("LANGFUSE_SECRET_KEY")
+	}
 
 	restClient := restclientgo.New(langfuseHost)
+	if cfg.HTTPClient != nil {
+		restClient.SetHTTPClient(cfg.HTTPClient)
+	}
+	compressor := newCompressor(cfg.Compression, cfg.CompressionThreshold)
 	restClient.SetRequestModifier(func(req *http.Request) *http.Request {
 		req.Header.Set("Authorization", basicAuth(publicKey, secretKey))
+		// Host 可能带有路径前缀（如自托管的 https://example.com/langfuse），按后缀匹配
+		if strings.HasSuffix(req.URL.Path, ingestionPath) {
+			if err := compressor.apply(req); err != nil {
+				log.Warnf(req.Context(), "compress request body error, send uncompressed: %s", err.Error())
+			}
+		}
 		return req
 	})
 
@@ -38,7 +75,63 @@ func New() *Client {
 }
 
 func (c *Client) Ingestion(ctx context.Context, req *Ingestion, res *IngestionResponse) error {
-	return c.restClient.Post(ctx, req, res)
+	if err := c.restClient.Post(ctx, req, res); err != nil {
+		return err
+	}
+	if !res.IsSuccess() {
+		return newStatusError(&res.Response)
+	}
+	return nil
+}
+
+func (c *Client) GetScoreConfig(ctx context.Context, req *GetScoreConfig, res *ScoreConfigResponse) error {
+	if err := c.restClient.Get(ctx, req, res); err != nil {
+		return err
+	}
+	if !res.IsSuccess() {
+		return newStatusError(&res.Response)
+	}
+	return nil
+}
+
+func (c *Client) GetPrompt(ctx context.Context, req *GetPrompt, res *PromptResponse) error {
+	if err := c.restClient.Get(ctx, req, res); err != nil {
+		return err
+	}
+	if !res.IsSuccess() {
+		return newStatusError(&res.Response)
+	}
+	return nil
+}
+
+func (c *Client) CreatePrompt(ctx context.Context, req *CreatePrompt, res *PromptResponse) error {
+	if err := c.restClient.Post(ctx, req, res); err != nil {
+		return err
+	}
+	if !res.IsSuccess() {
+		return newStatusError(&res.Response)
+	}
+	return nil
+}
+
+func (c *Client) UpdatePromptLabels(ctx context.Context, req *UpdatePromptLabels, res *PromptResponse) error {
+	if err := c.restClient.Patch(ctx, req, res); err != nil {
+		return err
+	}
+	if !res.IsSuccess() {
+		return newStatusError(&res.Response)
+	}
+	return nil
+}
+
+func (c *Client) ListPrompts(ctx context.Context, req *ListPrompts, res *ListPromptsResponse) error {
+	if err := c.restClient.Get(ctx, req, res); err != nil {
+		return err
+	}
+	if !res.IsSuccess() {
+		return newStatusError(&res.Response)
+	}
+	return nil
 }
 
 func basicAuth(publicKey, secretKey string) string {
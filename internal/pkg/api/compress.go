@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// EncodingGzip gzip 压缩
+	EncodingGzip = "gzip"
+	// EncodingZstd zstd 压缩
+	EncodingZstd = "zstd"
+
+	// DefaultCompressionThreshold 默认压缩阈值，小于该大小的请求体不压缩
+	DefaultCompressionThreshold = 1024
+)
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() any {
+			return gzip.NewWriter(io.Discard)
+		},
+	}
+	zstdEncoderOnce sync.Once
+	zstdEncoder     *zstd.Encoder
+	zstdEncoderErr  error
+)
+
+// compressor 按配置压缩请求体并设置 Content-Encoding
+type compressor struct {
+	encoding  string
+	threshold int64
+}
+
+func newCompressor(encoding string, threshold int) *compressor {
+	if encoding == "" {
+		return nil
+	}
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	return &compressor{
+		encoding:  encoding,
+		threshold: int64(threshold),
+	}
+}
+
+// apply 压缩请求体，大小未知或未达到阈值时保持原样
+func (c *compressor) apply(req *http.Request) error {
+	if c == nil || req.Body == nil || req.ContentLength < c.threshold {
+		return nil
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	_ = req.Body.Close()
+
+	compressed, err := c.compress(raw)
+	if err != nil {
+		// 压缩失败时恢复原始请求体
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+		return err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(compressed))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.ContentLength = int64(len(compressed))
+	req.Header.Set("Content-Encoding", c.encoding)
+	return nil
+}
+
+func (c *compressor) compress(raw []byte) ([]byte, error) {
+	switch c.encoding {
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w, _ := gzipWriterPool.Get().(*gzip.Writer)
+		defer gzipWriterPool.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		zstdEncoderOnce.Do(func() {
+			zstdEncoder, zstdEncoderErr = zstd.NewWriter(nil)
+		})
+		if zstdEncoderErr != nil {
+			return nil, zstdEncoderErr
+		}
+		return zstdEncoder.EncodeAll(raw, make([]byte, 0, len(raw)/2)), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", c.encoding)
+	}
+}
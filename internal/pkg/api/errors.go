@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/henomis/restclientgo"
+)
+
+// StatusError 服务端返回非成功状态码
+type StatusError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter 服务端通过 Retry-After 要求的等待时间
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("langfuse: unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+// IsNetworkError 判断是否为请求发送阶段的错误（连接失败、超时等）
+func IsNetworkError(err error) bool {
+	return errors.Is(err, restclientgo.ErrHTTPRequest)
+}
+
+func newStatusError(res *Response) error {
+	body := ""
+	if res.RawBody != nil {
+		body = *res.RawBody
+	}
+	return &StatusError{
+		StatusCode: res.Code,
+		Body:       body,
+		RetryAfter: res.RetryAfter(),
+	}
+}
@@ -3,26 +3,39 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/rongbiwei/langfuse-go/model"
 )
 
 const (
 	ContentTypeJSON = "application/json"
+
+	ingestionPath = "/api/public/ingestion"
+	promptsPath   = "/api/public/v2/prompts"
 )
 
 type Request struct{}
 
 type Ingestion struct {
 	Batch []model.IngestionEvent `json:"batch"`
+	// RawBatch 已编码的事件，非空时直接拼接为请求体，忽略 Batch
+	RawBatch []json.RawMessage `json:"-"`
 }
 
 func (t *Ingestion) Path() (string, error) {
-	return "/api/public/ingestion", nil
+	return ingestionPath, nil
 }
 
 func (t *Ingestion) Encode() (io.Reader, error) {
+	if len(t.RawBatch) > 0 {
+		return bytes.NewReader(t.encodeRaw()), nil
+	}
+
 	jsonBytes, err := json.Marshal(t)
 	if err != nil {
 		return nil, err
@@ -34,3 +47,192 @@ func (t *Ingestion) Encode() (io.Reader, error) {
 func (t *Ingestion) ContentType() string {
 	return ContentTypeJSON
 }
+
+// encodeRaw 由已编码的事件拼接请求体，避免再次序列化
+func (t *Ingestion) encodeRaw() []byte {
+	const prefix, suffix = `{"batch":[`, `]}`
+
+	size := len(prefix) + len(suffix) + len(t.RawBatch) - 1
+	for _, raw := range t.RawBatch {
+		size += len(raw)
+	}
+
+	body := make([]byte, 0, size)
+	body = append(body, prefix...)
+	for i, raw := range t.RawBatch {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = append(body, raw...)
+	}
+	body = append(body, suffix...)
+	return body
+}
+
+type GetScoreConfig struct {
+	ID string
+}
+
+func (t *GetScoreConfig) Path() (string, error) {
+	if t.ID == "" {
+		return "", errors.New("score config ID is required")
+	}
+	return "/api/public/score-configs/" + url.PathEscape(t.ID), nil
+}
+
+func (t *GetScoreConfig) Encode() (io.Reader, error) {
+	return nil, nil
+}
+
+func (t *GetScoreConfig) ContentType() string {
+	return ""
+}
+
+type GetPrompt struct {
+	Name string
+	// Version 指定版本，为 0 时按 Label 选择
+	Version int
+	// Label 指定标签，Version 与 Label 都为空时服务端返回 production
+	Label string
+}
+
+func (t *GetPrompt) Path() (string, error) {
+	if t.Name == "" {
+		return "", errors.New("prompt name is required")
+	}
+	if t.Version > 0 && t.Label != "" {
+		return "", errors.New("prompt version and label are mutually exclusive")
+	}
+
+	query := url.Values{}
+	if t.Version > 0 {
+		query.Set("version", strconv.Itoa(t.Version))
+	}
+	if t.Label != "" {
+		query.Set("label", t.Label)
+	}
+	path := promptsPath + "/" + url.PathEscape(t.Name)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+func (t *GetPrompt) Encode() (io.Reader, error) {
+	return nil, nil
+}
+
+func (t *GetPrompt) ContentType() string {
+	return ""
+}
+
+type CreatePrompt struct {
+	Name string           `json:"name"`
+	Type model.PromptType `json:"type"`
+	// Prompt 文本 prompt 为 string，对话 prompt 为 []model.ChatMessage
+	Prompt        any            `json:"prompt"`
+	Config        map[string]any `json:"config,omitempty"`
+	Labels        []string       `json:"labels,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	CommitMessage string         `json:"commitMessage,omitempty"`
+}
+
+func (t *CreatePrompt) Path() (string, error) {
+	if t.Name == "" {
+		return "", errors.New("prompt name is required")
+	}
+	return promptsPath, nil
+}
+
+func (t *CreatePrompt) Encode() (io.Reader, error) {
+	jsonBytes, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(jsonBytes), nil
+}
+
+func (t *CreatePrompt) ContentType() string {
+	return ContentTypeJSON
+}
+
+type UpdatePromptLabels struct {
+	Name    string `json:"-"`
+	Version int    `json:"-"`
+	// NewLabels 设置到该版本的标签，同名标签会从其他版本上移除
+	NewLabels []string `json:"newLabels"`
+}
+
+func (t *UpdatePromptLabels) Path() (string, error) {
+	if t.Name == "" {
+		return "", errors.New("prompt name is required")
+	}
+	if t.Version <= 0 {
+		return "", errors.New("prompt version is required")
+	}
+	return promptsPath + "/" + url.PathEscape(t.Name) + "/versions/" + strconv.Itoa(t.Version), nil
+}
+
+func (t *UpdatePromptLabels) Encode() (io.Reader, error) {
+	if t.NewLabels == nil {
+		t.NewLabels = []string{}
+	}
+	jsonBytes, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(jsonBytes), nil
+}
+
+func (t *UpdatePromptLabels) ContentType() string {
+	return ContentTypeJSON
+}
+
+type ListPrompts struct {
+	Name          string
+	Label         string
+	Tag           string
+	Page          int
+	Limit         int
+	FromUpdatedAt *time.Time
+	ToUpdatedAt   *time.Time
+}
+
+func (t *ListPrompts) Path() (string, error) {
+	query := url.Values{}
+	if t.Name != "" {
+		query.Set("name", t.Name)
+	}
+	if t.Label != "" {
+		query.Set("label", t.Label)
+	}
+	if t.Tag != "" {
+		query.Set("tag", t.Tag)
+	}
+	if t.Page > 0 {
+		query.Set("page", strconv.Itoa(t.Page))
+	}
+	if t.Limit > 0 {
+		query.Set("limit", strconv.Itoa(t.Limit))
+	}
+	if t.FromUpdatedAt != nil {
+		query.Set("fromUpdatedAt", t.FromUpdatedAt.UTC().Format(time.RFC3339Nano))
+	}
+	if t.ToUpdatedAt != nil {
+		query.Set("toUpdatedAt", t.ToUpdatedAt.UTC().Format(time.RFC3339Nano))
+	}
+	if len(query) == 0 {
+		return promptsPath, nil
+	}
+	return promptsPath + "?" + query.Encode(), nil
+}
+
+func (t *ListPrompts) Encode() (io.Reader, error) {
+	return nil, nil
+}
+
+func (t *ListPrompts) ContentType() string {
+	return ""
+}
@@ -4,15 +4,20 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/henomis/restclientgo"
+	"github.com/rongbiwei/langfuse-go/model"
 )
 
 type Response struct {
-	Code      int       `json:"-"`
-	RawBody   *string   `json:"-"`
-	Successes []Success `json:"successes"`
-	Errors    []Error   `json:"errors"`
+	Code      int         `json:"-"`
+	RawBody   *string     `json:"-"`
+	Headers   http.Header `json:"-"`
+	Successes []Success   `json:"successes"`
+	Errors    []Error     `json:"errors"`
 }
 
 type Success struct {
@@ -56,10 +61,63 @@ func (r *Response) Decode(body io.Reader) error {
 	return json.NewDecoder(body).Decode(r)
 }
 
-func (r *Response) SetHeaders(_ restclientgo.Headers) error {
+func (r *Response) SetHeaders(headers restclientgo.Headers) error {
+	r.Headers = http.Header(headers)
 	return nil
 }
 
+// RetryAfter 解析 Retry-After 头，支持秒数与 HTTP 日期两种格式
+func (r *Response) RetryAfter() time.Duration {
+	value := strings.TrimSpace(r.Headers.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 type IngestionResponse struct {
 	Response
 }
+
+type ScoreConfigResponse struct {
+	Response
+	ScoreConfig model.ScoreConfig
+}
+
+func (r *ScoreConfigResponse) Decode(body io.Reader) error {
+	return json.NewDecoder(body).Decode(&r.ScoreConfig)
+}
+
+type PromptResponse struct {
+	Response
+	Prompt model.Prompt
+}
+
+func (r *PromptResponse) Decode(body io.Reader) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	r.Prompt, err = model.UnmarshalPrompt(b)
+	return err
+}
+
+type ListPromptsResponse struct {
+	Response
+	PromptList model.PromptList
+}
+
+func (r *ListPromptsResponse) Decode(body io.Reader) error {
+	return json.NewDecoder(body).Decode(&r.PromptList)
+}
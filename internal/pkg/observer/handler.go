@@ -3,51 +3,64 @@ package observer
 import (
 	"context"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
 type command int
 
 const (
-	commanFlush command = iota
-	commandFlushAndWait
+	commandFlush command = iota
+	commandShutdown
 )
 
 const (
 	defaultTickerPeriod = 1 * time.Second
 	maxHandleGoroutines = 5 // 最大并发处理协程数
+	drainInterval       = 50 * time.Millisecond
 )
 
+// request 发送给 listen 协程的命令，ctx 控制本次处理的截止时间
+type request struct {
+	ctx  context.Context
+	cmd  command
+	done chan error
+}
+
 type handler[T any] struct {
-	queue        *queue[T]
-	fn           EventHandler[T]
-	commandCh    chan command
-	tickerPeriod time.Duration
-	semaphore    chan struct{}  // 协程信号量
-	wg           sync.WaitGroup // 等待所有 handle goroutine 完成
-	closed       atomic.Bool    // 标记是否已关闭
+	queue     *queue[T]
+	fn        EventHandler[T]
+	commandCh chan request
+	ticker    *time.Ticker
+	semaphore chan struct{}  // 协程信号量
+	wg        sync.WaitGroup // 等待所有 handle goroutine 完成
+	exited    chan struct{}  // listen 退出后关闭
+	stop      context.CancelFunc
 }
 
-func newHandler[T any](queue *queue[T], fn EventHandler[T]) *handler[T] {
+func newHandler[T any](queue *queue[T], fn EventHandler[T], tickerPeriod time.Duration) *handler[T] {
 	return &handler[T]{
-		queue:        queue,
-		fn:           fn,
-		commandCh:    make(chan command),
-		tickerPeriod: defaultTickerPeriod,
-		semaphore:    make(chan struct{}, maxHandleGoroutines),
+		queue:     queue,
+		fn:        fn,
+		commandCh: make(chan request),
+		ticker:    time.NewTicker(tickerPeriod),
+		semaphore: make(chan struct{}, maxHandleGoroutines),
+		exited:    make(chan struct{}),
 	}
 }
 
+// withTick 调整定时周期，listen 启动后调用同样生效
 func (h *handler[T]) withTick(period time.Duration) *handler[T] {
-	h.tickerPeriod = period
+	if period > 0 {
+		h.ticker.Reset(period)
+	}
 	return h
 }
 
 func (h *handler[T]) listen(ctx context.Context) {
-	ticker := time.NewTicker(h.tickerPeriod)
-	defer ticker.Stop()
-	defer h.markClosed()
+	defer h.ticker.Stop()
+	defer close(h.exited)
+	// 无论因 Shutdown 还是 ctx 结束退出，都关闭队列，之后的投递返回 ErrClosed 而不是堆积在无人处理的队列中
+	defer h.queue.Close()
 
 	for {
 		select {
@@ -57,7 +70,7 @@ func (h *handler[T]) listen(ctx context.Context) {
 			// 处理队列中剩余的数据
 			h.handle(ctx)
 			return
-		case <-ticker.C:
+		case <-h.ticker.C:
 			// 尝试获取信号量，获取不到则跳过本次
 			select {
 			case h.semaphore <- struct{}{}:
@@ -72,28 +85,28 @@ func (h *handler[T]) listen(ctx context.Context) {
 			default:
 				// 已达到最大并发数，跳过本次
 			}
-		case cmd, ok := <-h.commandCh:
-			if !ok {
-				return
+		case req := <-h.commandCh:
+			// 等待所有正在执行的 handle 完成
+			h.wg.Wait()
+			// 命令的处理在调用方 ctx 或 listen ctx 结束时都会被取消
+			reqCtx, cancel := context.WithCancel(req.ctx)
+			stopCancel := context.AfterFunc(ctx, cancel)
+			switch req.cmd {
+			case commandFlush:
+				h.handle(reqCtx)
+				req.done <- nil
+			case commandShutdown:
+				req.done <- h.drain(reqCtx)
 			}
-
-			if cmd == commanFlush {
-				h.handle(ctx)
-			} else if cmd == commandFlushAndWait {
-				// 等待所有正在执行的 handle 完成
-				h.wg.Wait()
-				// 最后再处理一次队列中剩余的数据
-				h.handle(ctx)
-				close(h.commandCh)
+			stopCancel()
+			cancel()
+			if req.cmd == commandShutdown {
+				return
 			}
 		}
 	}
 }
 
-func (h *handler[T]) markClosed() {
-	h.closed.Store(true)
-}
-
 func (h *handler[T]) handle(ctx context.Context) {
 	events := h.queue.All()
 	if len(events) == 0 {
@@ -108,29 +121,56 @@ func (h *handler[T]) handle(ctx context.Context) {
 	}
 }
 
-func (h *handler[T]) flush() {
-	if h.closed.Load() {
-		return
-	}
-	select {
-	case h.commandCh <- commanFlush:
-	default:
-		// channel 已关闭或满，忽略
+// drain 反复处理直到队列清空（包括重新入队的失败事件）或 ctx 结束，
+// 处理后仍有剩余时（多为退避中的重试事件）间隔 drainInterval 再处理，避免空转
+func (h *handler[T]) drain(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		h.handle(ctx)
+		if h.queue.Len() == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(drainInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
 	}
 }
 
-func (h *handler[T]) flushAndWait() {
-	if h.closed.Load() {
-		return
+// shutdown 发送 shutdown 命令，无论是否在 ctx 结束前完成都停止 listen，并等待正在进行的处理返回
+func (h *handler[T]) shutdown(ctx context.Context) error {
+	err := h.send(ctx, commandShutdown)
+	h.stop()
+	<-h.exited
+	return err
+}
+
+// send 发送命令并等待 listen 协程处理完成
+func (h *handler[T]) send(ctx context.Context, cmd command) error {
+	req := request{
+		ctx:  ctx,
+		cmd:  cmd,
+		done: make(chan error, 1),
 	}
-	
-	// 使用 recover 防止向已关闭 channel 发送导致 panic
-	defer func() {
-		recover()
-	}()
-	
-	h.commandCh <- commandFlushAndWait
-	// 等待 channel 关闭
-	for range h.commandCh {
+
+	select {
+	case h.commandCh <- req:
+	case <-h.exited:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -2,23 +2,111 @@ package observer
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	// ErrClosed 观察者已关闭（Shutdown 或创建时的 ctx 已结束），事件不再入队
+	ErrClosed = errors.New("observer: closed")
+	// ErrDropped 队列已满，事件按背压策略被丢弃，已通过 WithDropHandler 回调
+	ErrDropped = errors.New("observer: event dropped")
+)
+
 type EventHandler[T any] func(ctx context.Context, events []T) []T
 
+// Option 观察者配置项
+type Option func(*options)
+
+type options struct {
+	tickerPeriod  time.Duration
+	maxQueueBytes int64
+	maxQueueItems int
+	policy        Policy
+	blockTimeout  time.Duration
+	sampleRate    float64
+	onDrop        func(item any)
+}
+
+// WithTickerPeriod 设置定时处理周期
+func WithTickerPeriod(period time.Duration) Option {
+	return func(o *options) {
+		if period > 0 {
+			o.tickerPeriod = period
+		}
+	}
+}
+
+// WithMaxQueueBytes 设置队列最大内存（字节）
+func WithMaxQueueBytes(size int64) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxQueueBytes = size
+		}
+	}
+}
+
+// WithMaxQueueItems 设置队列最大元素个数
+func WithMaxQueueItems(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxQueueItems = n
+		}
+	}
+}
+
+// WithPolicy 设置队列满时的背压策略，timeout 仅对 PolicyBlockTimeout 生效
+func WithPolicy(policy Policy, timeout time.Duration) Option {
+	return func(o *options) {
+		o.policy = policy
+		o.blockTimeout = timeout
+	}
+}
+
+// WithSampleRate 设置 PolicySample 下保留新事件的比例，取值 0~1
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.sampleRate = rate
+	}
+}
+
+// WithDropHandler 设置事件被队列丢弃时的回调
+func WithDropHandler(fn func(item any)) Option {
+	return func(o *options) {
+		o.onDrop = fn
+	}
+}
+
 type Observer[T any] struct {
 	queue   *queue[T]
 	handler *handler[T]
 }
 
-func NewObserver[T any](ctx context.Context, fn EventHandler[T]) *Observer[T] {
-	queue := newQueue[T]()
+func NewObserver[T any](ctx context.Context, fn EventHandler[T], opts ...Option) *Observer[T] {
+	cfg := options{
+		tickerPeriod:  defaultTickerPeriod,
+		maxQueueBytes: DefaultMaxMemoryBytes,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	queue := newQueueWithMaxSize[T](cfg.maxQueueBytes)
+	queue.maxItems = cfg.maxQueueItems
+	queue.policy = cfg.policy
+	queue.timeout = cfg.blockTimeout
+	queue.sampleRate = cfg.sampleRate
+	if cfg.onDrop != nil {
+		queue.onDrop = func(item T) {
+			cfg.onDrop(item)
+		}
+	}
 
 	o := &Observer[T]{
 		queue:   queue,
-		handler: newHandler(queue, fn),
+		handler: newHandler(queue, fn, cfg.tickerPeriod),
 	}
+	ctx, o.handler.stop = context.WithCancel(ctx)
 	go o.handler.listen(ctx)
 
 	return o
@@ -29,25 +117,30 @@ func (o *Observer[T]) WithTick(tick time.Duration) *Observer[T] {
 	return o
 }
 
-func (o *Observer[T]) Dispatch(event T) {
-	o.queue.Enqueue(event)
+// Dispatch 投递事件，队列已关闭时返回 ErrClosed，被背压策略丢弃时返回 ErrDropped
+func (o *Observer[T]) Dispatch(event T) error {
+	return o.queue.Enqueue(event)
 }
 
-func (o *Observer[T]) Flush() {
-	o.handler.flush()
+// Dropped 返回队列累计丢弃的事件数
+func (o *Observer[T]) Dropped() uint64 {
+	return o.queue.Dropped()
 }
 
-func (o *Observer[T]) Wait(ctx context.Context) {
-	done := make(chan struct{})
-	go func() {
-		o.handler.flushAndWait()
-		close(done)
-	}()
+// Len 返回当前排队的事件数
+func (o *Observer[T]) Len() int {
+	return o.queue.Len()
+}
 
-	select {
-	case <-ctx.Done():
-		return
-	case <-done:
-		return
-	}
+// Flush 等待正在执行的处理完成后立即处理一次队列，可重复调用
+func (o *Observer[T]) Flush(ctx context.Context) error {
+	return o.handler.send(ctx, commandFlush)
+}
+
+// Shutdown 处理完队列中所有事件后停止，ctx 结束时返回 ctx.Err()；返回时处理协程已退出，
+// 未发送的事件留在队列中，之后的 Dispatch 不再入队
+func (o *Observer[T]) Shutdown(ctx context.Context) error {
+	err := o.handler.shutdown(ctx)
+	o.queue.Close()
+	return err
 }
@@ -2,12 +2,33 @@ package observer
 
 import (
 	"encoding/json"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	// DefaultMaxMemoryBytes 默认最大内存限制 128MB
 	DefaultMaxMemoryBytes = 50 * 1024 * 1024
+	// defaultHighWatermark PolicySample 下开始采样的队列占用比例
+	defaultHighWatermark = 0.8
+)
+
+// Policy 队列满时的背压策略
+type Policy int
+
+const (
+	// PolicyBlock 阻塞调用方直到队列有空间
+	PolicyBlock Policy = iota
+	// PolicyBlockTimeout 最多阻塞指定时间，超时后丢弃新事件
+	PolicyBlockTimeout
+	// PolicyDropNewest 丢弃新事件
+	PolicyDropNewest
+	// PolicyDropOldest 丢弃队首最旧的事件为新事件腾出空间
+	PolicyDropOldest
+	// PolicySample 队列占用超过高水位后按比例采样，队列满时丢弃新事件
+	PolicySample
 )
 
 type queue[T any] struct {
@@ -16,43 +37,164 @@ type queue[T any] struct {
 	items       []T
 	currentSize int64 // 当前队列数据大小（字节）
 	maxSize     int64 // 最大允许大小（字节）
+	maxItems    int   // 最大元素个数，0 表示不限制
+
+	policy     Policy
+	timeout    time.Duration // PolicyBlockTimeout 的最长阻塞时间
+	sampleRate float64       // PolicySample 下保留新事件的比例
+	onDrop     func(T)       // 事件被丢弃时的回调，在锁外调用
+	dropped    atomic.Uint64 // 累计丢弃数
+	closed     bool          // 关闭后 Enqueue 不再接收新元素
 }
 
-// Enqueue 入队，当队列大小超过限制时阻塞
-func (q *queue[T]) Enqueue(item T) {
+// Enqueue 入队，队列超过限制时按背压策略阻塞或丢弃（返回 ErrDropped），
+// 队列已关闭或阻塞等待期间被关闭时返回 ErrClosed，此时不视为丢弃、不回调 onDrop
+func (q *queue[T]) Enqueue(item T) error {
 	itemSize := int64(q.estimateItemSize(item))
 
 	q.mu.Lock()
-	defer q.mu.Unlock()
-
-	// 当队列大小超过限制时，等待直到有空间
-	for q.currentSize+itemSize > q.maxSize && len(q.items) > 0 {
-		q.cond.Wait()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	var evicted []T
+	accepted := true
+	switch q.policy {
+	case PolicyBlock:
+		// 当队列大小超过限制时，等待直到有空间或队列关闭
+		for q.full(itemSize) && !q.closed {
+			q.cond.Wait()
+		}
+	case PolicyBlockTimeout:
+		accepted = q.waitTimeout(itemSize)
+	case PolicyDropNewest:
+		accepted = !q.full(itemSize)
+	case PolicyDropOldest:
+		for q.full(itemSize) {
+			evicted = append(evicted, q.pop())
+		}
+	case PolicySample:
+		//nolint:gosec // 采样不需要安全随机数
+		if q.full(itemSize) || (q.underPressure(itemSize) && rand.Float64() >= q.sampleRate) {
+			accepted = false
+		}
 	}
+	if q.closed {
+		// 阻塞等待期间队列被关闭，被挤出的旧元素仍按丢弃处理
+		q.mu.Unlock()
+		q.drop(evicted)
+		return ErrClosed
+	}
+	if accepted {
+		q.items = append(q.items, item)
+		q.currentSize += itemSize
+	} else {
+		evicted = append(evicted, item)
+	}
+	q.mu.Unlock()
 
-	q.items = append(q.items, item)
-	q.currentSize += itemSize
+	q.drop(evicted)
+	if !accepted {
+		return ErrDropped
+	}
+	return nil
 }
 
-// TryEnqueue 尝试入队，如果队列已满则返回 false（不阻塞）
+// TryEnqueue 尝试入队，如果队列已满则丢弃并返回 false（不阻塞）
 func (q *queue[T]) TryEnqueue(item T) bool {
 	itemSize := int64(q.estimateItemSize(item))
 
 	q.mu.Lock()
-	defer q.mu.Unlock()
-
 	// 队列已满，直接返回失败
-	if q.currentSize+itemSize > q.maxSize && len(q.items) > 0 {
+	if q.full(itemSize) {
+		q.mu.Unlock()
+		q.drop([]T{item})
 		return false
 	}
 
 	q.items = append(q.items, item)
 	q.currentSize += itemSize
+	q.mu.Unlock()
 	return true
 }
 
-// estimateItemSize 使用 json.Marshal 估算元素大小
+// full 判断加入 itemSize 大小的元素后是否超过限制，空队列总是可以入队
+func (q *queue[T]) full(itemSize int64) bool {
+	if len(q.items) == 0 {
+		return false
+	}
+	if q.maxItems > 0 && len(q.items) >= q.maxItems {
+		return true
+	}
+	return q.currentSize+itemSize > q.maxSize
+}
+
+// underPressure 判断队列占用是否超过高水位
+func (q *queue[T]) underPressure(itemSize int64) bool {
+	if q.maxItems > 0 && float64(len(q.items)) >= float64(q.maxItems)*defaultHighWatermark {
+		return true
+	}
+	return float64(q.currentSize+itemSize) > float64(q.maxSize)*defaultHighWatermark
+}
+
+// waitTimeout 在持有锁的情况下最多等待 timeout，返回是否有空间
+func (q *queue[T]) waitTimeout(itemSize int64) bool {
+	if !q.full(itemSize) {
+		return true
+	}
+	deadline := time.Now().Add(q.timeout)
+	timer := time.AfterFunc(q.timeout, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	for q.full(itemSize) && !q.closed {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		q.cond.Wait()
+	}
+	return true
+}
+
+// pop 在持有锁的情况下移除队首元素
+func (q *queue[T]) pop() T {
+	item := q.items[0]
+	var zero T
+	q.items[0] = zero
+	q.items = q.items[1:]
+	q.currentSize -= int64(q.estimateItemSize(item))
+	if q.currentSize < 0 {
+		q.currentSize = 0
+	}
+	return item
+}
+
+// drop 统计并回调被丢弃的元素
+func (q *queue[T]) drop(items []T) {
+	if len(items) == 0 {
+		return
+	}
+	q.dropped.Add(uint64(len(items)))
+	if q.onDrop != nil {
+		for _, item := range items {
+			q.onDrop(item)
+		}
+	}
+}
+
+// Sizer 可自行提供大小的元素，队列不再使用 json.Marshal 估算
+type Sizer interface {
+	Size() int
+}
+
+// estimateItemSize 估算元素大小，未实现 Sizer 时使用 json.Marshal
 func (q *queue[T]) estimateItemSize(item T) int {
+	if s, ok := any(item).(Sizer); ok {
+		return s.Size()
+	}
 	data, err := json.Marshal(item)
 	if err != nil {
 		return 1024 // 序列化失败时返回保守估算值
@@ -68,12 +210,7 @@ func (q *queue[T]) Dequeue() T {
 		var zero T
 		return zero
 	}
-	item := q.items[0]
-	q.items = q.items[1:]
-	q.currentSize -= int64(q.estimateItemSize(item))
-	if q.currentSize < 0 {
-		q.currentSize = 0
-	}
+	item := q.pop()
 	q.cond.Signal()
 	return item
 }
@@ -92,12 +229,13 @@ func (q *queue[T]) Size() int64 {
 	return q.currentSize
 }
 
+// Dropped 返回累计丢弃的元素个数
+func (q *queue[T]) Dropped() uint64 {
+	return q.dropped.Load()
+}
+
 func newQueue[T any]() *queue[T] {
-	q := &queue[T]{
-		maxSize: DefaultMaxMemoryBytes,
-	}
-	q.cond = sync.NewCond(&q.mu)
-	return q
+	return newQueueWithMaxSize[T](DefaultMaxMemoryBytes)
 }
 
 // newQueueWithMaxSize 创建指定最大内存限制的队列
@@ -109,6 +247,14 @@ func newQueueWithMaxSize[T any](maxSize int64) *queue[T] {
 	return q
 }
 
+// Close 关闭队列，唤醒阻塞的 Enqueue，之后的 Enqueue 直接丢弃新元素
+func (q *queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
 // Clear 清空
 func (q *queue[T]) Clear() {
 	q.mu.Lock()
@@ -0,0 +1,363 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSegmentBytes 默认单个段文件大小上限 8MB
+	DefaultMaxSegmentBytes = 8 * 1024 * 1024
+	// DefaultMaxTotalBytes 默认 spool 目录总大小上限 512MB
+	DefaultMaxTotalBytes = 512 * 1024 * 1024
+	// DefaultFsyncInterval 默认定期刷盘周期
+	DefaultFsyncInterval = time.Second
+
+	segmentExt  = ".seg"
+	headerBytes = 8 // 4 字节长度 + 4 字节 crc32
+)
+
+var (
+	// ErrFull spool 已达到总大小上限
+	ErrFull = errors.New("spool: max total size reached")
+	// ErrClosed spool 已关闭
+	ErrClosed = errors.New("spool: closed")
+)
+
+// FsyncPolicy 刷盘策略
+type FsyncPolicy int
+
+const (
+	// FsyncNever 不主动刷盘，交给操作系统
+	FsyncNever FsyncPolicy = iota
+	// FsyncInterval 按固定周期刷盘
+	FsyncInterval
+	// FsyncAlways 每次写入后刷盘
+	FsyncAlways
+)
+
+// Options spool 配置
+type Options struct {
+	Dir             string
+	MaxSegmentBytes int64
+	MaxTotalBytes   int64
+	Fsync           FsyncPolicy
+	FsyncInterval   time.Duration
+}
+
+// Record 回放得到的一条记录
+type Record struct {
+	Segment uint64
+	Data    []byte
+}
+
+// Spool 分段写前日志，记录全部确认后删除对应段文件
+type Spool struct {
+	mu         sync.Mutex
+	opts       Options
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+	totalSize  int64
+	pending    map[uint64]int   // 段 -> 未确认记录数
+	sizes      map[uint64]int64 // 段 -> 文件大小
+	dirty      bool
+	closed     bool
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// Open 打开 spool 目录，返回目录中尚未确认的记录用于回放
+func Open(opts Options) (*Spool, []Record, error) {
+	if opts.Dir == "" {
+		return nil, nil, errors.New("spool: dir is required")
+	}
+	if opts.MaxSegmentBytes <= 0 {
+		opts.MaxSegmentBytes = DefaultMaxSegmentBytes
+	}
+	if opts.MaxTotalBytes <= 0 {
+		opts.MaxTotalBytes = DefaultMaxTotalBytes
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = DefaultFsyncInterval
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("spool: create dir: %w", err)
+	}
+
+	s := &Spool{
+		opts:    opts,
+		pending: make(map[uint64]int),
+		sizes:   make(map[uint64]int64),
+	}
+
+	seqs, err := s.segments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []Record
+	for _, seq := range seqs {
+		segRecords, size, errRead := s.readSegment(seq)
+		if errRead != nil {
+			return nil, nil, errRead
+		}
+		if len(segRecords) == 0 {
+			_ = os.Remove(s.path(seq))
+			continue
+		}
+		s.pending[seq] = len(segRecords)
+		s.sizes[seq] = size
+		s.totalSize += size
+		records = append(records, segRecords...)
+	}
+
+	next := uint64(1)
+	if len(seqs) > 0 {
+		next = seqs[len(seqs)-1] + 1
+	}
+	if err = s.openSegment(next); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.Fsync == FsyncInterval {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncLoop()
+	}
+
+	return s, records, nil
+}
+
+// Append 追加一条记录，返回记录所在段
+func (s *Spool) Append(data []byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	recordSize := int64(headerBytes + len(data))
+	if s.totalSize+recordSize > s.opts.MaxTotalBytes {
+		return 0, ErrFull
+	}
+	if s.activeSize > 0 && s.activeSize+recordSize > s.opts.MaxSegmentBytes {
+		if err := s.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	buf := make([]byte, recordSize)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[headerBytes:], data)
+
+	if _, err := s.active.Write(buf); err != nil {
+		return 0, fmt.Errorf("spool: write: %w", err)
+	}
+	if s.opts.Fsync == FsyncAlways {
+		if err := s.active.Sync(); err != nil {
+			return 0, fmt.Errorf("spool: sync: %w", err)
+		}
+	} else {
+		s.dirty = true
+	}
+
+	s.activeSize += recordSize
+	s.totalSize += recordSize
+	s.sizes[s.activeSeq] = s.activeSize
+	s.pending[s.activeSeq]++
+	return s.activeSeq, nil
+}
+
+// Ack 确认段中的一条记录已处理完成，段内记录全部确认且不再写入时删除段文件
+func (s *Spool) Ack(seq uint64) {
+	if seq == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pending[seq]; !ok {
+		return
+	}
+	s.pending[seq]--
+	if s.pending[seq] <= 0 && seq != s.activeSeq {
+		s.removeSegment(seq)
+	}
+}
+
+// Close 刷盘并关闭当前段，未确认的段保留到下次 Open 回放
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.active.Sync(); err != nil {
+		_ = s.active.Close()
+		return fmt.Errorf("spool: sync: %w", err)
+	}
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("spool: close: %w", err)
+	}
+	if s.pending[s.activeSeq] <= 0 {
+		s.removeSegment(s.activeSeq)
+	}
+	return nil
+}
+
+// syncLoop 定期刷盘
+func (s *Spool) syncLoop() {
+	ticker := time.NewTicker(s.opts.FsyncInterval)
+	defer ticker.Stop()
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty {
+				_ = s.active.Sync()
+				s.dirty = false
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// rotate 关闭当前段并打开新段
+func (s *Spool) rotate() error {
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("spool: sync: %w", err)
+	}
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("spool: close: %w", err)
+	}
+	s.dirty = false
+
+	prev := s.activeSeq
+	if err := s.openSegment(prev + 1); err != nil {
+		return err
+	}
+	if s.pending[prev] <= 0 {
+		s.removeSegment(prev)
+	}
+	return nil
+}
+
+func (s *Spool) openSegment(seq uint64) error {
+	f, err := os.OpenFile(s.path(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("spool: open segment: %w", err)
+	}
+	s.active = f
+	s.activeSeq = seq
+	s.activeSize = 0
+	s.pending[seq] = 0
+	s.sizes[seq] = 0
+	return nil
+}
+
+func (s *Spool) removeSegment(seq uint64) {
+	_ = os.Remove(s.path(seq))
+	s.totalSize -= s.sizes[seq]
+	delete(s.pending, seq)
+	delete(s.sizes, seq)
+}
+
+// readSegment 读取段中的有效记录，校验失败的记录被跳过，截断的尾部被忽略
+func (s *Spool) readSegment(seq uint64) ([]Record, int64, error) {
+	f, err := os.Open(s.path(seq))
+	if err != nil {
+		return nil, 0, fmt.Errorf("spool: open segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("spool: stat segment: %w", err)
+	}
+
+	var records []Record
+	reader := bufio.NewReader(f)
+	header := make([]byte, headerBytes)
+	var offset int64
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			// io.EOF 为正常结束，io.ErrUnexpectedEOF 为写入中途崩溃留下的截断记录
+			break
+		}
+		offset += headerBytes
+		length := binary.BigEndian.Uint32(header[0:4])
+		checksum := binary.BigEndian.Uint32(header[4:8])
+		// 只按文件剩余大小校验长度：空段允许写入超过 MaxSegmentBytes 的记录，配置也可能在重启之间调小
+		if int64(length) > info.Size()-offset {
+			// 长度字段已损坏或记录被截断，无法定位后续记录
+			break
+		}
+		data := make([]byte, length)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			break
+		}
+		offset += int64(length)
+		if crc32.ChecksumIEEE(data) != checksum {
+			continue
+		}
+		records = append(records, Record{Segment: seq, Data: data})
+	}
+	return records, info.Size(), nil
+}
+
+// segments 返回目录中已有的段序号（升序）
+func (s *Spool) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("spool: read dir: %w", err)
+	}
+
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, errParse := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if errParse != nil || seq == 0 {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (s *Spool) path(seq uint64) string {
+	return filepath.Join(s.opts.Dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
@@ -0,0 +1,191 @@
+package spool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openSpool(t *testing.T, opts Options) (*Spool, []Record) {
+	t.Helper()
+	s, records, err := Open(opts)
+	if err != nil {
+		t.Fatalf("open spool: %v", err)
+	}
+	return s, records
+}
+
+func appendAll(t *testing.T, s *Spool, data ...[]byte) []uint64 {
+	t.Helper()
+	segs := make([]uint64, 0, len(data))
+	for _, d := range data {
+		seg, err := s.Append(d)
+		if err != nil {
+			t.Fatalf("append %d bytes: %v", len(d), err)
+		}
+		segs = append(segs, seg)
+	}
+	return segs
+}
+
+func closeSpool(t *testing.T, s *Spool) {
+	t.Helper()
+	if err := s.Close(); err != nil {
+		t.Fatalf("close spool: %v", err)
+	}
+}
+
+func assertRecords(t *testing.T, records []Record, want ...[]byte) {
+	t.Helper()
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, r := range records {
+		if !bytes.Equal(r.Data, want[i]) {
+			t.Errorf("record %d = %q, want %q", i, r.Data, want[i])
+		}
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestReplayUnackedRecords(t *testing.T) {
+	dir := t.TempDir()
+	s, records := openSpool(t, Options{Dir: dir, MaxSegmentBytes: 32})
+	assertRecords(t, records)
+
+	a, b, c := []byte("first record"), []byte("second record"), []byte("third record")
+	segs := appendAll(t, s, a, b, c)
+	if segs[0] == segs[2] {
+		t.Fatalf("expected records to span segments, got %v", segs)
+	}
+	s.Ack(segs[1])
+	closeSpool(t, s)
+
+	s, records = openSpool(t, Options{Dir: dir, MaxSegmentBytes: 32})
+	defer closeSpool(t, s)
+	assertRecords(t, records, a, c)
+}
+
+func TestAckRemovesSegments(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := openSpool(t, Options{Dir: dir, MaxSegmentBytes: 32})
+	for _, seg := range appendAll(t, s, []byte("first record"), []byte("second record"), []byte("third record")) {
+		s.Ack(seg)
+	}
+	closeSpool(t, s)
+
+	if files := segmentFiles(t, dir); len(files) != 0 {
+		t.Fatalf("acked segments left on disk: %v", files)
+	}
+	s, records := openSpool(t, Options{Dir: dir})
+	defer closeSpool(t, s)
+	assertRecords(t, records)
+}
+
+func TestReplayOversizedRecords(t *testing.T) {
+	small := []byte("small")
+	large := bytes.Repeat([]byte("x"), 2000)
+
+	tests := []struct {
+		name      string
+		writeMax  int64
+		replayMax int64
+	}{
+		{name: "record larger than segment", writeMax: 1024, replayMax: 1024},
+		{name: "segment limit lowered between restarts", writeMax: 0, replayMax: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, _ := openSpool(t, Options{Dir: dir, MaxSegmentBytes: tt.writeMax})
+			appendAll(t, s, small, large, small)
+			closeSpool(t, s)
+
+			s, records := openSpool(t, Options{Dir: dir, MaxSegmentBytes: tt.replayMax})
+			defer closeSpool(t, s)
+			assertRecords(t, records, small, large, small)
+		})
+	}
+}
+
+func TestReplayCorruptedSegment(t *testing.T) {
+	a, b := []byte("first record"), []byte("second record")
+
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		want    [][]byte
+	}{
+		{
+			name: "truncated tail",
+			corrupt: func(data []byte) []byte {
+				return data[:len(data)-3]
+			},
+			want: [][]byte{a},
+		},
+		{
+			name: "truncated header",
+			corrupt: func(data []byte) []byte {
+				return data[:headerBytes+len(a)+4]
+			},
+			want: [][]byte{a},
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(data []byte) []byte {
+				data[headerBytes] ^= 0xff
+				return data
+			},
+			want: [][]byte{b},
+		},
+		{
+			name: "length beyond file",
+			corrupt: func(data []byte) []byte {
+				binary.BigEndian.PutUint32(data[0:4], 1<<30)
+				return data
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, _ := openSpool(t, Options{Dir: dir})
+			segs := appendAll(t, s, a, b)
+			closeSpool(t, s)
+
+			path := s.path(segs[0])
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = os.WriteFile(path, tt.corrupt(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			s, records := openSpool(t, Options{Dir: dir})
+			defer closeSpool(t, s)
+			assertRecords(t, records, tt.want...)
+		})
+	}
+}
+
+func TestAppendMaxTotalBytes(t *testing.T) {
+	s, _ := openSpool(t, Options{Dir: t.TempDir(), MaxTotalBytes: 32})
+	defer closeSpool(t, s)
+
+	appendAll(t, s, bytes.Repeat([]byte("x"), 16))
+	if _, err := s.Append(bytes.Repeat([]byte("x"), 16)); err != ErrFull {
+		t.Fatalf("append over total size: got %v, want ErrFull", err)
+	}
+}
@@ -3,76 +3,177 @@ package langfuse
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
 	"github.com/rongbiwei/langfuse-go/internal/pkg/observer"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/ratelimit"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/spool"
 	"github.com/rongbiwei/langfuse-go/model"
 )
 
 const (
 	defaultFlushInterval = 500 * time.Millisecond
-	// batchSize 每次批量发送的数据量
+	defaultParallel      = 2
+	// batchSize 每次批量发送的数据量（按压缩前大小计算，服务端限制的是解压后的大小）
 	batchSize = 3 * 1024 * 1024
 )
 
 // Langfuse 跟踪对象
 type Langfuse struct {
 	flushInterval time.Duration
+	parallel      int
+	batchSize     int
+	retryPolicy   RetryPolicy
+	dropHandler   DropHandler
 	client        *api.Client
-	observer      *observer.Observer[model.IngestionEvent]
+	observer      *observer.Observer[envelope]
+	spool         *spool.Spool
 	location      *time.Location
+	clock         Clock
+
+	idGenerator      IDGenerator
+	traceIDGenerator IDGenerator
+
+	requestLimiter *ratelimit.Limiter
+	byteLimiter    *ratelimit.Limiter
+	pauseUntil     atomic.Int64 // 429 暂停截止时间（UnixNano）
+
+	seq          atomic.Uint64 // 事件投递序号，见 envelope.seq
+	closeMu      sync.RWMutex  // 保证 Shutdown 之后不再有事件入队
+	closed       bool
+	shuttingDown atomic.Bool
+	sent         atomic.Uint64
+	retried      atomic.Uint64
+	dropped      atomic.Uint64
+
+	repanic           bool
+	panicFlushTimeout time.Duration
+
+	scoreConfigs scoreConfigCache
+	prompts      promptCache
+	defaults     defaults
 }
 
 // New 创建一个新的Langfuse
 func New(ctx context.Context, parallel int) *Langfuse {
-	client := api.New()
-	loc, _ := time.LoadLocation("Asia/Shanghai")
+	return NewWithOptions(ctx, WithParallel(parallel))
+}
+
+// NewWithOptions 使用配置项创建一个新的Langfuse，未设置的凭据回退到环境变量
+func NewWithOptions(ctx context.Context, opts ...Option) *Langfuse {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.traceIDGenerator == nil {
+		cfg.traceIDGenerator = cfg.idGenerator
+	}
+	if cfg.timezone != "" {
+		loc, err := time.LoadLocation(cfg.timezone)
+		if err != nil {
+			log.Warnf(ctx, "langfuse: load timezone %q: %v, using UTC", cfg.timezone, err)
+			loc = time.UTC
+		}
+		cfg.location = loc
+	}
+	if !cfg.compression.supported() {
+		log.Warnf(ctx, "langfuse: unsupported compression %q, send requests uncompressed", cfg.compression)
+		cfg.compression = CompressionNone
+	}
+
 	l := &Langfuse{
-		flushInterval: defaultFlushInterval,
-		client:        client,
-		location:      loc,
-		observer: observer.NewObserver(
-			ctx,
-			func(ctx context.Context, events []model.IngestionEvent) []model.IngestionEvent {
-				if len(events) == 0 {
-					return nil
-				}
-				pushDataBatch(ctx, parallel, client, events, nil)
+		flushInterval: cfg.flushInterval,
+		parallel:      cfg.parallel,
+		batchSize:     cfg.batchSize,
+		retryPolicy:   cfg.retryPolicy,
+		dropHandler:   cfg.dropHandler,
+		location:      cfg.location,
+		clock:         cfg.clock,
+
+		idGenerator:      cfg.idGenerator,
+		traceIDGenerator: cfg.traceIDGenerator,
+
+		repanic:           cfg.repanic,
+		panicFlushTimeout: cfg.panicFlushTimeout,
+		defaults:          cfg.defaults.withEnv(),
+		prompts:           promptCache{ttl: cfg.promptCacheTTL},
+
+		requestLimiter: ratelimit.New(cfg.requestsPerSecond, math.Max(1, cfg.requestsPerSecond)),
+		byteLimiter:    ratelimit.New(float64(cfg.bytesPerSecond), float64(cfg.bytesPerSecond)),
+		client: api.NewWithConfig(api.Config{
+			Host:       cfg.host,
+			PublicKey:  cfg.publicKey,
+			SecretKey:  cfg.secretKey,
+			HTTPClient: cfg.httpClient,
+
+			Compression:          string(cfg.compression),
+			CompressionThreshold: cfg.compressionThreshold,
+		}),
+	}
+	l.observer = observer.NewObserver(
+		ctx,
+		func(ctx context.Context, events []envelope) []envelope {
+			if len(events) == 0 {
 				return nil
-			},
-		),
+			}
+			ready, deferred := due(events, time.Now())
+			return append(deferred, l.pushDataBatch(ctx, coalesce(ready))...)
+		},
+		observer.WithTickerPeriod(cfg.flushInterval),
+		observer.WithMaxQueueBytes(cfg.queueSize),
+		observer.WithMaxQueueItems(cfg.backpressure.MaxItems),
+		observer.WithPolicy(cfg.backpressure.Policy, cfg.backpressure.Timeout),
+		observer.WithSampleRate(cfg.backpressure.SampleRate),
+		observer.WithDropHandler(l.onQueueDrop),
+	)
+	if cfg.spool != nil {
+		l.openSpool(ctx, *cfg.spool)
 	}
+	l.warmupPrompts(ctx, cfg.promptWarmups)
 	return l
 }
 
-// pushDataBatch 推送数据--- 批量
-func pushDataBatch(ctx context.Context, parallel int, client *api.Client, events []model.IngestionEvent, failEvents *[]model.IngestionEvent) {
+// pushDataBatch 推送数据--- 批量，返回需要重新入队的失败事件
+func (l *Langfuse) pushDataBatch(ctx context.Context, events []envelope) []envelope {
+	parallel := l.parallel
 	if parallel <= 0 {
-		parallel = 2
+		parallel = defaultParallel
+	}
+	maxBatchSize := l.batchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = batchSize
 	}
-	var wg sync.WaitGroup
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		failEvents []envelope
+	)
 	maxGoroutines, index := parallel, 0
 	goroutineSemaphore := make(chan struct{}, maxGoroutines)
 
 	for index < len(events) && len(events) > 0 {
-		batchData := make([]model.IngestionEvent, 0)
+		batchData := make([]envelope, 0)
 		currentSize := 0
 		for i := index; i < len(events); i++ {
-			byteArr, _ := json.Marshal(events[i])
-			if currentSize+len(byteArr) > batchSize {
+			itemSize := events[i].Size()
+			if currentSize+itemSize > maxBatchSize {
 				if i == index {
 					// 單條數據超過大小
 					batchData = append(batchData, events[i])
+					currentSize += itemSize
 					index++
 				}
 				break
 			}
-			currentSize += len(byteArr)
+			currentSize += itemSize
 			batchData = append(batchData, events[i])
 			index++
 		}
@@ -80,67 +181,113 @@ func pushDataBatch(ctx context.Context, parallel int, client *api.Client, events
 		if len(batchData) > 0 {
 			goroutineSemaphore <- struct{}{}
 			wg.Add(1)
-			go func(batch []model.IngestionEvent) {
+			go func(batch []envelope, size int) {
 				defer func() {
 					<-goroutineSemaphore
 					wg.Done()
 				}()
-				if err := ingest(ctx, client, batch); err != nil {
-					log.Errorf(ctx, "ingest error: %s", err.Error())
+				var retry []envelope
+				if err := l.throttle(ctx, size); err != nil {
+					// 限流等待被取消时批次尚未发送，原样重新入队，不计失败次数也不确认 spool
+					log.Warnf(ctx, "throttle wait interrupted, requeue %d events: %s", len(batch), err.Error())
+					retry = batch
+				} else if res, err := ingest(ctx, l.client, batch); err != nil {
+					l.pauseOnRateLimit(ctx, err)
+					retry = l.retryFailed(ctx, batch, err)
+				} else {
+					retry = l.retryPartial(ctx, batch, res.Errors)
 				}
-			}(batchData)
+				if len(retry) == 0 {
+					return
+				}
+				mu.Lock()
+				failEvents = append(failEvents, retry...)
+				mu.Unlock()
+			}(batchData, currentSize)
 		}
 	}
 	wg.Wait()
+	return failEvents
 }
 
+// WithFlushInterval 调整定时发送的周期
 func (l *Langfuse) WithFlushInterval(d time.Duration) *Langfuse {
 	l.flushInterval = d
+	l.observer.WithTick(d)
 	return l
 }
 
-func ingest(ctx context.Context, client *api.Client, events []model.IngestionEvent) error {
+func ingest(ctx context.Context, client *api.Client, envelopes []envelope) (*api.IngestionResponse, error) {
+	raw := make([]json.RawMessage, 0, len(envelopes))
+	for _, env := range envelopes {
+		raw = append(raw, env.raw)
+	}
 	req := api.Ingestion{
-		Batch: events,
+		RawBatch: raw,
 	}
 
 	res := api.IngestionResponse{}
-	return client.Ingestion(ctx, &req, &res)
+	if err := client.Ingestion(ctx, &req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
 }
 
 // Trace 构建跟踪
 func (l *Langfuse) Trace(t *model.Trace) (*model.Trace, error) {
-	t.ID = buildID(&t.ID)
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
+	t.ID = l.buildTraceID(&t.ID)
+	now := l.now()
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        buildID(nil),
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeTraceCreate,
 			Timestamp: now,
 			Body:      t,
 		},
-	)
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
 	return t, nil
 }
 
 // TraceWithTime 构建跟踪并指定时间戳
 func (l *Langfuse) TraceWithTime(t *model.Trace, timestamp time.Time) (*model.Trace, error) {
-	t.ID = buildID(&t.ID)
-	l.observer.Dispatch(
+	t.ID = l.buildTraceID(&t.ID)
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        t.ID,
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeTraceCreate,
 			Timestamp: timestamp,
 			Body:      t,
 		},
-	)
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TraceUpdate 按 ID 更新 trace，只发送已设置的字段，服务端按 ID upsert；同一发送窗口内的多次更新会合并为一个事件
+func (l *Langfuse) TraceUpdate(t *model.Trace) (*model.Trace, error) {
+	if t.ID == "" {
+		return nil, fmt.Errorf("trace ID is required")
+	}
+	if err := l.dispatch(
+		model.IngestionEvent{
+			ID:        l.buildID(nil),
+			Type:      model.IngestionEventTypeTraceCreate,
+			Timestamp: l.now(),
+			Body:      t,
+		},
+		skipDefaults,
+	); err != nil {
+		return nil, err
+	}
 	return t, nil
 }
 
-// Generation 构建生成
+// Generation 构建生成，设置了 Prompt 时自动关联 prompt 名称与版本
 func (l *Langfuse) Generation(g *model.Generation, parentID *string) (*model.Generation, error) {
 	if g.TraceID == "" {
 		traceID, err := l.createTrace(g.Name)
@@ -151,23 +298,24 @@ func (l *Langfuse) Generation(g *model.Generation, parentID *string) (*model.Gen
 		g.TraceID = traceID
 	}
 
-	g.ID = buildID(&g.ID)
+	g.ID = l.buildID(&g.ID)
+	linkPrompt(g)
 
 	if parentID != nil {
 		g.ParentObservationID = *parentID
 	}
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
+	now := l.now()
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        buildID(nil),
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeGenerationCreate,
 			Timestamp: now,
 			Body:      g,
 		},
-	)
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
 	return g, nil
 }
 
@@ -182,25 +330,38 @@ func (l *Langfuse) GenerationWithTime(g *model.Generation, parentID *string, tim
 		g.TraceID = traceID
 	}
 
-	g.ID = buildID(&g.ID)
+	g.ID = l.buildID(&g.ID)
+	linkPrompt(g)
 
 	if parentID != nil {
 		g.ParentObservationID = *parentID
 	}
 
-	l.observer.Dispatch(
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        g.ID,
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeGenerationCreate,
 			Timestamp: timestamp,
 			Body:      g,
 		},
-	)
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
 	return g, nil
 }
 
-// GenerationEnd 结束一个生成
+// GenerationEnd 结束一个生成，未设置 EndTime 时使用当前时间
 func (l *Langfuse) GenerationEnd(g *model.Generation) (*model.Generation, error) {
+	if g.EndTime == nil {
+		now := l.now()
+		g.EndTime = &now
+	}
+	return l.updateGeneration(g)
+}
+
+// updateGeneration 发送 generation-update 事件
+func (l *Langfuse) updateGeneration(g *model.Generation) (*model.Generation, error) {
 	if g.ID == "" {
 		return nil, fmt.Errorf("generation ID is required")
 	}
@@ -208,18 +369,17 @@ func (l *Langfuse) GenerationEnd(g *model.Generation) (*model.Generation, error)
 	if g.TraceID == "" {
 		return nil, fmt.Errorf("trace ID is required")
 	}
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        buildID(nil),
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeGenerationUpdate,
-			Timestamp: now,
+			Timestamp: l.now(),
 			Body:      g,
 		},
-	)
+		skipDefaults,
+	); err != nil {
+		return nil, err
+	}
 
 	return g, nil
 }
@@ -234,36 +394,23 @@ func (l *Langfuse) GenerationEndWithTime(g *model.Generation, timestamp time.Tim
 		return nil, fmt.Errorf("trace ID is required")
 	}
 
-	l.observer.Dispatch(
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        g.ID,
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeGenerationUpdate,
 			Timestamp: timestamp,
 			Body:      g,
 		},
-	)
+		skipDefaults,
+	); err != nil {
+		return nil, err
+	}
 	return g, nil
 }
 
-// Score 构建分数
+// Score 构建分数，设置了 ConfigID 时会先按分数配置校验
 func (l *Langfuse) Score(s *model.Score) (*model.Score, error) {
-	if s.TraceID == "" {
-		return nil, fmt.Errorf("trace ID is required")
-	}
-	s.ID = buildID(&s.ID)
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
-		model.IngestionEvent{
-			ID:        buildID(nil),
-			Type:      model.IngestionEventTypeScoreCreate,
-			Timestamp: now,
-			Body:      s,
-		},
-	)
-	return s, nil
+	return l.ScoreWithContext(context.Background(), s)
 }
 
 // Span 构建span
@@ -277,29 +424,38 @@ func (l *Langfuse) Span(s *model.Span, parentID *string) (*model.Span, error) {
 		s.TraceID = traceID
 	}
 
-	s.ID = buildID(&s.ID)
+	s.ID = l.buildID(&s.ID)
 
 	if parentID != nil {
 		s.ParentObservationID = *parentID
 	}
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
+	now := l.now()
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        buildID(nil),
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeSpanCreate,
 			Timestamp: now,
 			Body:      s,
 		},
-	)
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
 
-// SpanEnd 结束一个span
+// SpanEnd 结束一个span，未设置 EndTime 时使用当前时间
 func (l *Langfuse) SpanEnd(s *model.Span) (*model.Span, error) {
+	if s.EndTime == nil {
+		now := l.now()
+		s.EndTime = &now
+	}
+	return l.updateSpan(s)
+}
+
+// updateSpan 发送 span-update 事件
+func (l *Langfuse) updateSpan(s *model.Span) (*model.Span, error) {
 	if s.ID == "" {
 		return nil, fmt.Errorf("span ID is required")
 	}
@@ -307,18 +463,17 @@ func (l *Langfuse) SpanEnd(s *model.Span) (*model.Span, error) {
 	if s.TraceID == "" {
 		return nil, fmt.Errorf("trace ID is required")
 	}
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        buildID(nil),
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeSpanUpdate,
-			Timestamp: now,
+			Timestamp: l.now(),
 			Body:      s,
 		},
-	)
+		skipDefaults,
+	); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
@@ -334,23 +489,23 @@ func (l *Langfuse) Event(e *model.Event, parentID *string) (*model.Event, error)
 		e.TraceID = traceID
 	}
 
-	e.ID = buildID(&e.ID)
+	e.ID = l.buildID(&e.ID)
 
 	if parentID != nil {
 		e.ParentObservationID = *parentID
 	}
-	now := time.Now()
-	if l.location != nil {
-		now = now.In(l.location)
-	}
-	l.observer.Dispatch(
+	now := l.now()
+	if err := l.dispatch(
 		model.IngestionEvent{
-			ID:        uuid.New().String(),
+			ID:        l.buildID(nil),
 			Type:      model.IngestionEventTypeEventCreate,
 			Timestamp: now,
 			Body:      e,
 		},
-	)
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
 
 	return e, nil
 }
@@ -368,17 +523,73 @@ func (l *Langfuse) createTrace(traceName string) (string, error) {
 	return trace.ID, nil
 }
 
-// Flush 资源清理，等待所有观察者事件发送完成
-func (l *Langfuse) Flush(ctx context.Context) {
-	l.observer.Wait(ctx)
+// Flush 发送当前队列中的所有事件（退避中的重试事件除外），不影响后续使用，可重复调用
+func (l *Langfuse) Flush(ctx context.Context) error {
+	if err := l.observer.Flush(ctx); err != nil {
+		if errors.Is(err, observer.ErrClosed) {
+			return ErrClosed
+		}
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接收新事件，在 ctx 截止前发送完队列中和正在发送的事件，关闭后各方法返回 ErrClosed。
+// ctx 在发送完成前结束或运行期间有事件被丢弃时返回 *ShutdownError，其中包含发送、重试与丢弃的统计
+func (l *Langfuse) Shutdown(ctx context.Context) error {
+	if !l.shuttingDown.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+
+	// 等待进行中的 dispatch 结束（Block 策略下可能阻塞在队列上），最多等到 ctx 截止
+	locked := make(chan struct{})
+	go func() {
+		l.closeMu.Lock()
+		l.closed = true
+		l.closeMu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-ctx.Done():
+	}
+
+	// 返回时发送协程已退出，正在发送的事件已确认、丢弃或回到队列中
+	err := l.observer.Shutdown(ctx)
+	if errors.Is(err, observer.ErrClosed) {
+		err = nil
+	}
+	// 队列关闭后阻塞的 dispatch 会立即返回，全部结束后才能关闭 spool
+	<-locked
+	l.closeSpool(ctx)
+
+	stats := l.Stats()
+	log.Infof(ctx, "langfuse shutdown: sent=%d retried=%d dropped=%d queue_dropped=%d unsent=%d",
+		stats.Sent, stats.Retried, stats.Dropped, stats.QueueDropped, stats.Queued)
+	if err != nil || stats.Queued > 0 || stats.Dropped > 0 || stats.QueueDropped > 0 {
+		return &ShutdownError{Stats: stats, Err: err}
+	}
+	return nil
+}
+
+// now 当前时间（配置的时区）
+func (l *Langfuse) now() time.Time {
+	return l.clock.Now().In(l.location)
+}
+
+// buildID 构建ID，id 为空时使用配置的 IDGenerator 生成
+func (l *Langfuse) buildID(id *string) string {
+	if id == nil || *id == "" {
+		return l.idGenerator.NewID()
+	}
+
+	return *id
 }
 
-// buildID 构建ID
-func buildID(id *string) string {
-	if id == nil {
-		return uuid.New().String()
-	} else if *id == "" {
-		return uuid.New().String()
+// buildTraceID 构建 trace ID，id 为空时使用配置的 trace IDGenerator 生成
+func (l *Langfuse) buildTraceID(id *string) string {
+	if id == nil || *id == "" {
+		return l.traceIDGenerator.NewID()
 	}
 
 	return *id
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // IngestionEventType 类型
 type IngestionEventType string
@@ -13,6 +16,16 @@ const (
 	IngestionEventTypeSpanCreate       = "span-create"
 	IngestionEventTypeSpanUpdate       = "span-update"
 	IngestionEventTypeEventCreate      = "event-create"
+
+	IngestionEventTypeObservationCreate = "observation-create"
+	IngestionEventTypeObservationUpdate = "observation-update"
+	IngestionEventTypeAgentCreate       = "agent-create"
+	IngestionEventTypeToolCreate        = "tool-create"
+	IngestionEventTypeChainCreate       = "chain-create"
+	IngestionEventTypeRetrieverCreate   = "retriever-create"
+	IngestionEventTypeEvaluatorCreate   = "evaluator-create"
+	IngestionEventTypeEmbeddingCreate   = "embedding-create"
+	IngestionEventTypeGuardrailCreate   = "guardrail-create"
 )
 
 // IngestionEvent 事件
@@ -22,23 +35,25 @@ type IngestionEvent struct {
 	Timestamp time.Time          `json:"timestamp"`
 	Metadata  any
 	Body      any `json:"body"`
-	FailCount int
+	// FailCount 发送失败次数，仅客户端使用
+	FailCount int `json:"-"`
 }
 
 // Trace 跟踪
 type Trace struct {
-	ID        string     `json:"id,omitempty"`
-	Timestamp *time.Time `json:"timestamp,omitempty"`
-	Name      string     `json:"name,omitempty"`
-	UserID    string     `json:"userId,omitempty"`
-	Input     any        `json:"input,omitempty"`
-	Output    any        `json:"output,omitempty"`
-	SessionID string     `json:"sessionId,omitempty"`
-	Release   string     `json:"release,omitempty"`
-	Version   string     `json:"version,omitempty"`
-	Metadata  any        `json:"metadata,omitempty"`
-	Tags      []string   `json:"tags,omitempty"`
-	Public    bool       `json:"public,omitempty"`
+	ID          string     `json:"id,omitempty"`
+	Timestamp   *time.Time `json:"timestamp,omitempty"`
+	Name        string     `json:"name,omitempty"`
+	UserID      string     `json:"userId,omitempty"`
+	Input       any        `json:"input,omitempty"`
+	Output      any        `json:"output,omitempty"`
+	SessionID   string     `json:"sessionId,omitempty"`
+	Release     string     `json:"release,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	Metadata    any        `json:"metadata,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Public      bool       `json:"public,omitempty"`
+	Environment string     `json:"environment,omitempty"`
 }
 
 // ObservationLevel 观察级别
@@ -63,14 +78,19 @@ type Generation struct {
 	StatusMessage       string           `json:"statusMessage,omitempty"`
 	ParentObservationID string           `json:"parentObservationId,omitempty"`
 	Version             string           `json:"version,omitempty"`
+	Environment         string           `json:"environment,omitempty"`
 	ID                  string           `json:"id,omitempty"`
 	EndTime             *time.Time       `json:"endTime,omitempty"`
 	CompletionStartTime *time.Time       `json:"completionStartTime,omitempty"`
 	Model               string           `json:"model,omitempty"`
 	ModelParameters     any              `json:"modelParameters,omitempty"`
 	Usage               Usage            `json:"usage,omitempty"`
+	UsageDetails        UsageDetails     `json:"usageDetails,omitempty"`
+	CostDetails         CostDetails      `json:"costDetails,omitempty"`
 	PromptName          string           `json:"promptName,omitempty"`
 	PromptVersion       int              `json:"promptVersion,omitempty"`
+	// Prompt 使用的 prompt，创建时用于填充未设置的 PromptName 与 PromptVersion
+	Prompt Prompt `json:"-"`
 }
 
 // Usage .
@@ -114,14 +134,64 @@ const (
 	ModelUsageUnitImages       UsageUnit = "IMAGES"
 )
 
-// Score 分数
+// ScoreDataType 分数数据类型
+type ScoreDataType string
+
+const (
+	ScoreDataTypeNumeric     ScoreDataType = "NUMERIC"
+	ScoreDataTypeCategorical ScoreDataType = "CATEGORICAL"
+	ScoreDataTypeBoolean     ScoreDataType = "BOOLEAN"
+)
+
+// Score 分数，NUMERIC 与 BOOLEAN（0/1）使用 Value，CATEGORICAL 使用 StringValue；
+// 目标为 TraceID（可选 ObservationID）、SessionID 或 DatasetRunID 之一
 type Score struct {
-	ID            string  `json:"id,omitempty"`
-	TraceID       string  `json:"traceId,omitempty"`
-	Name          string  `json:"name,omitempty"`
-	Value         float64 `json:"value,omitempty"`
-	ObservationID string  `json:"observationId,omitempty"`
-	Comment       string  `json:"comment,omitempty"`
+	ID            string        `json:"id,omitempty"`
+	TraceID       string        `json:"traceId,omitempty"`
+	SessionID     string        `json:"sessionId,omitempty"`
+	DatasetRunID  string        `json:"datasetRunId,omitempty"`
+	Name          string        `json:"name,omitempty"`
+	Value         float64       `json:"value"`
+	StringValue   string        `json:"-"`
+	DataType      ScoreDataType `json:"dataType,omitempty"`
+	ConfigID      string        `json:"configId,omitempty"`
+	ObservationID string        `json:"observationId,omitempty"`
+	Comment       string        `json:"comment,omitempty"`
+	Metadata      any           `json:"metadata,omitempty"`
+}
+
+// MarshalJSON value 总是输出（0 也是合法分数），CATEGORICAL 输出字符串
+func (s Score) MarshalJSON() ([]byte, error) {
+	type alias Score
+	out := struct {
+		alias
+		Value any `json:"value"`
+	}{
+		alias: alias(s),
+		Value: s.Value,
+	}
+	if s.DataType == ScoreDataTypeCategorical {
+		out.Value = s.StringValue
+	}
+	return json.Marshal(out)
+}
+
+// ScoreConfig 分数配置
+type ScoreConfig struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	DataType    ScoreDataType         `json:"dataType"`
+	IsArchived  bool                  `json:"isArchived"`
+	MinValue    *float64              `json:"minValue,omitempty"`
+	MaxValue    *float64              `json:"maxValue,omitempty"`
+	Categories  []ScoreConfigCategory `json:"categories,omitempty"`
+	Description string                `json:"description,omitempty"`
+}
+
+// ScoreConfigCategory 分类分数的可选项
+type ScoreConfigCategory struct {
+	Value float64 `json:"value"`
+	Label string  `json:"label"`
 }
 
 // Span 跨度
@@ -136,6 +206,7 @@ type Span struct {
 	StatusMessage       string           `json:"statusMessage,omitempty"`
 	ParentObservationID string           `json:"parentObservationId,omitempty"`
 	Version             string           `json:"version,omitempty"`
+	Environment         string           `json:"environment,omitempty"`
 	ID                  string           `json:"id,omitempty"`
 	EndTime             *time.Time       `json:"endTime,omitempty"`
 }
@@ -152,6 +223,7 @@ type Event struct {
 	StatusMessage       string           `json:"statusMessage,omitempty"`
 	ParentObservationID string           `json:"parentObservationId,omitempty"`
 	Version             string           `json:"version,omitempty"`
+	Environment         string           `json:"environment,omitempty"`
 	ID                  string           `json:"id,omitempty"`
 }
 
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ObservationType observation 类型
+type ObservationType string
+
+const (
+	ObservationTypeSpan       ObservationType = "SPAN"
+	ObservationTypeGeneration ObservationType = "GENERATION"
+	ObservationTypeEvent      ObservationType = "EVENT"
+	ObservationTypeAgent      ObservationType = "AGENT"
+	ObservationTypeTool       ObservationType = "TOOL"
+	ObservationTypeChain      ObservationType = "CHAIN"
+	ObservationTypeRetriever  ObservationType = "RETRIEVER"
+	ObservationTypeEvaluator  ObservationType = "EVALUATOR"
+	ObservationTypeEmbedding  ObservationType = "EMBEDDING"
+	ObservationTypeGuardrail  ObservationType = "GUARDRAIL"
+)
+
+// ObservationBody observation 通用字段
+type ObservationBody struct {
+	TraceID             string           `json:"traceId,omitempty"`
+	Name                string           `json:"name,omitempty"`
+	StartTime           *time.Time       `json:"startTime,omitempty"`
+	Metadata            any              `json:"metadata,omitempty"`
+	Input               any              `json:"input,omitempty"`
+	Output              any              `json:"output,omitempty"`
+	Level               ObservationLevel `json:"level,omitempty"`
+	StatusMessage       string           `json:"statusMessage,omitempty"`
+	ParentObservationID string           `json:"parentObservationId,omitempty"`
+	Version             string           `json:"version,omitempty"`
+	Environment         string           `json:"environment,omitempty"`
+	ID                  string           `json:"id,omitempty"`
+	EndTime             *time.Time       `json:"endTime,omitempty"`
+}
+
+// Base 返回通用字段
+func (o *ObservationBody) Base() *ObservationBody {
+	return o
+}
+
+// Observation 通用 observation，用于 observation-create/observation-update，Type 决定具体类型
+type Observation struct {
+	ObservationBody
+	Type                ObservationType `json:"type"`
+	CompletionStartTime *time.Time      `json:"completionStartTime,omitempty"`
+	Model               string          `json:"model,omitempty"`
+	ModelParameters     any             `json:"modelParameters,omitempty"`
+	Usage               *Usage          `json:"usage,omitempty"`
+	UsageDetails        UsageDetails    `json:"usageDetails,omitempty"`
+	CostDetails         CostDetails     `json:"costDetails,omitempty"`
+	PromptName          string          `json:"promptName,omitempty"`
+	PromptVersion       int             `json:"promptVersion,omitempty"`
+}
+
+// Agent 智能体
+type Agent struct {
+	ObservationBody
+}
+
+// Tool 工具调用，ToolName 与 Arguments 分别在 Name、Input 为空时作为默认值，并记录到 metadata
+type Tool struct {
+	ObservationBody
+	ToolName  string `json:"-"`
+	Arguments any    `json:"-"`
+}
+
+// MarshalJSON 将工具名称与参数写入通用字段
+func (t Tool) MarshalJSON() ([]byte, error) {
+	type alias Tool
+	a := alias(t)
+	if a.Name == "" {
+		a.Name = a.ToolName
+	}
+	if a.Input == nil {
+		a.Input = a.Arguments
+	}
+	a.Metadata = mergeMetadata(a.Metadata, M{"toolName": a.ToolName, "arguments": a.Arguments})
+	return json.Marshal(a)
+}
+
+// Chain 链
+type Chain struct {
+	ObservationBody
+}
+
+// Retriever 检索
+type Retriever struct {
+	ObservationBody
+}
+
+// Evaluator 评估
+type Evaluator struct {
+	ObservationBody
+}
+
+// Embedding 向量化
+type Embedding struct {
+	ObservationBody
+	Model           string       `json:"model,omitempty"`
+	ModelParameters any          `json:"modelParameters,omitempty"`
+	Usage           *Usage       `json:"usage,omitempty"`
+	UsageDetails    UsageDetails `json:"usageDetails,omitempty"`
+	CostDetails     CostDetails  `json:"costDetails,omitempty"`
+	Dimensions      int          `json:"-"`
+}
+
+// MarshalJSON 将向量维度记录到 metadata
+func (e Embedding) MarshalJSON() ([]byte, error) {
+	type alias Embedding
+	a := alias(e)
+	if a.Dimensions > 0 {
+		a.Metadata = mergeMetadata(a.Metadata, M{"dimensions": a.Dimensions})
+	}
+	return json.Marshal(a)
+}
+
+// Guardrail 安全护栏
+type Guardrail struct {
+	ObservationBody
+}
+
+// mergeMetadata 将 extra 中非空的键合并到 metadata，metadata 不是对象时保留在 "metadata" 键下，已有的键不会被覆盖
+func mergeMetadata(metadata any, extra M) any {
+	merged := M{}
+	switch m := metadata.(type) {
+	case nil:
+	case M:
+		for k, v := range m {
+			merged[k] = v
+		}
+	case map[string]any:
+		for k, v := range m {
+			merged[k] = v
+		}
+	default:
+		merged["metadata"] = m
+	}
+	for k, v := range extra {
+		if v == nil || v == "" {
+			continue
+		}
+		if _, ok := merged[k]; !ok {
+			merged[k] = v
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PromptType prompt 类型
+type PromptType string
+
+const (
+	PromptTypeText PromptType = "text"
+	PromptTypeChat PromptType = "chat"
+)
+
+// ChatMessageType chat prompt 中消息的类型
+type ChatMessageType string
+
+const (
+	ChatMessageTypeMessage     ChatMessageType = "chatmessage"
+	ChatMessageTypePlaceholder ChatMessageType = "placeholder"
+)
+
+// Prompt 从 Langfuse 获取的 prompt，具体类型为 *TextPrompt 或 *ChatPrompt
+type Prompt interface {
+	Meta() PromptMeta
+}
+
+// PromptMeta prompt 的版本信息与配置
+type PromptMeta struct {
+	Name          string         `json:"name"`
+	Version       int            `json:"version"`
+	Type          PromptType     `json:"type"`
+	Config        map[string]any `json:"config,omitempty"`
+	Labels        []string       `json:"labels,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	CommitMessage string         `json:"commitMessage,omitempty"`
+	// IsFallback 为调用方提供的兜底 prompt，并非来自服务端
+	IsFallback bool `json:"-"`
+}
+
+// Meta 返回 prompt 的版本信息与配置
+func (m PromptMeta) Meta() PromptMeta {
+	return m
+}
+
+// TextPrompt 文本 prompt
+type TextPrompt struct {
+	PromptMeta
+	Prompt string `json:"prompt"`
+}
+
+// ChatPrompt 对话 prompt
+type ChatPrompt struct {
+	PromptMeta
+	Prompt []ChatMessage `json:"prompt"`
+}
+
+// ChatMessage 对话消息，Type 为 placeholder 时按 Name 注入外部的消息列表
+type ChatMessage struct {
+	Type    ChatMessageType `json:"type,omitempty"`
+	Role    string          `json:"role,omitempty"`
+	Content string          `json:"content,omitempty"`
+	Name    string          `json:"name,omitempty"`
+}
+
+// IsPlaceholder 是否为消息占位符
+func (m ChatMessage) IsPlaceholder() bool {
+	return m.Type == ChatMessageTypePlaceholder
+}
+
+// UnmarshalPrompt 按 type 字段解码为 *TextPrompt 或 *ChatPrompt
+func UnmarshalPrompt(data []byte) (Prompt, error) {
+	var meta PromptMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+
+	switch meta.Type {
+	case PromptTypeText:
+		p := &TextPrompt{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	case PromptTypeChat:
+		p := &ChatPrompt{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown prompt type %q", meta.Type)
+	}
+}
+
+// PromptSummary prompt 列表中的一项，包含该名称下的所有版本
+type PromptSummary struct {
+	Name          string         `json:"name"`
+	Type          PromptType     `json:"type,omitempty"`
+	Versions      []int          `json:"versions"`
+	Labels        []string       `json:"labels,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	LastUpdatedAt time.Time      `json:"lastUpdatedAt"`
+	LastConfig    map[string]any `json:"lastConfig,omitempty"`
+}
+
+// PromptList 分页的 prompt 列表
+type PromptList struct {
+	Data []PromptSummary `json:"data"`
+	Meta PageMeta        `json:"meta"`
+}
+
+// PageMeta 分页信息
+type PageMeta struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	TotalItems int `json:"totalItems"`
+	TotalPages int `json:"totalPages"`
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// promptVariable 匹配 {{variable}}，允许花括号内两侧有空白
+var promptVariable = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// PromptCompileError 编译 prompt 时变量或占位符不匹配；编译结果仍会返回，缺失的变量保持原样
+type PromptCompileError struct {
+	Prompt              string
+	MissingVariables    []string
+	UnusedVariables     []string
+	MissingPlaceholders []string
+	UnusedPlaceholders  []string
+}
+
+func (e *PromptCompileError) Error() string {
+	var parts []string
+	for _, p := range []struct {
+		desc  string
+		names []string
+	}{
+		{"missing variables", e.MissingVariables},
+		{"unused variables", e.UnusedVariables},
+		{"missing placeholders", e.MissingPlaceholders},
+		{"unused placeholders", e.UnusedPlaceholders},
+	} {
+		if len(p.names) > 0 {
+			parts = append(parts, p.desc+" "+strings.Join(p.names, ", "))
+		}
+	}
+	return fmt.Sprintf("compile prompt %q: %s", e.Prompt, strings.Join(parts, "; "))
+}
+
+func (e *PromptCompileError) empty() bool {
+	return len(e.MissingVariables) == 0 && len(e.UnusedVariables) == 0 &&
+		len(e.MissingPlaceholders) == 0 && len(e.UnusedPlaceholders) == 0
+}
+
+// Compile 替换 {{variable}}，有缺失或未使用的变量时返回 *PromptCompileError
+func (p *TextPrompt) Compile(vars map[string]any) (string, error) {
+	c := newPromptCompiler(vars)
+	out := c.render(p.Prompt)
+	return out, c.err(p.Name, nil, nil)
+}
+
+// Compile 替换每条消息中的 {{variable}} 并将占位符展开为 placeholders 中同名的消息列表，
+// 有缺失或未使用的变量、占位符时返回 *PromptCompileError
+func (p *ChatPrompt) Compile(vars map[string]any, placeholders map[string][]ChatMessage) (ChatMessages, error) {
+	c := newPromptCompiler(vars)
+	usedPlaceholders := make(map[string]bool, len(placeholders))
+	var missingPlaceholders []string
+
+	out := make(ChatMessages, 0, len(p.Prompt))
+	for _, msg := range p.Prompt {
+		if msg.IsPlaceholder() {
+			messages, ok := placeholders[msg.Name]
+			if !ok {
+				missingPlaceholders = append(missingPlaceholders, msg.Name)
+				continue
+			}
+			usedPlaceholders[msg.Name] = true
+			out = append(out, messages...)
+			continue
+		}
+		msg.Content = c.render(msg.Content)
+		out = append(out, msg)
+	}
+
+	var unusedPlaceholders []string
+	for name := range placeholders {
+		if !usedPlaceholders[name] {
+			unusedPlaceholders = append(unusedPlaceholders, name)
+		}
+	}
+	sort.Strings(unusedPlaceholders)
+	return out, c.err(p.Name, missingPlaceholders, unusedPlaceholders)
+}
+
+// ChatMessages 编译后的对话消息
+type ChatMessages []ChatMessage
+
+// ToM 转换为 Generation.Input 使用的 []M，只保留 role 与 content
+func (m ChatMessages) ToM() []M {
+	out := make([]M, 0, len(m))
+	for _, msg := range m {
+		out = append(out, M{
+			"role":    msg.Role,
+			"content": msg.Content,
+		})
+	}
+	return out
+}
+
+// promptCompiler 记录编译过程中用到与缺失的变量
+type promptCompiler struct {
+	vars    map[string]any
+	used    map[string]bool
+	missing map[string]bool
+}
+
+func newPromptCompiler(vars map[string]any) *promptCompiler {
+	return &promptCompiler{
+		vars:    vars,
+		used:    make(map[string]bool, len(vars)),
+		missing: make(map[string]bool),
+	}
+}
+
+// render 替换模板中的变量，缺失的变量保持原样
+func (c *promptCompiler) render(template string) string {
+	return promptVariable.ReplaceAllStringFunc(template, func(match string) string {
+		name := promptVariable.FindStringSubmatch(match)[1]
+		value, ok := c.vars[name]
+		if !ok {
+			c.missing[name] = true
+			return match
+		}
+		c.used[name] = true
+		return formatVariable(value)
+	})
+}
+
+func (c *promptCompiler) err(prompt string, missingPlaceholders, unusedPlaceholders []string) error {
+	e := &PromptCompileError{
+		Prompt:              prompt,
+		MissingVariables:    sortedKeys(c.missing),
+		MissingPlaceholders: missingPlaceholders,
+		UnusedPlaceholders:  unusedPlaceholders,
+	}
+	for name := range c.vars {
+		if !c.used[name] {
+			e.UnusedVariables = append(e.UnusedVariables, name)
+		}
+	}
+	sort.Strings(e.UnusedVariables)
+	if e.empty() {
+		return nil
+	}
+	return e
+}
+
+// formatVariable 字符串原样输出，其他类型使用 JSON 编码
+func formatVariable(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// sortedKeys 返回排序后的键，m 为空时返回 nil，与其他未设置的字段保持一致
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTextPromptCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		prompt  string
+		vars    map[string]any
+		want    string
+		wantErr *PromptCompileError
+	}{
+		{
+			name:   "replaces variables",
+			prompt: "Hello {{name}}, welcome to {{place}}",
+			vars:   map[string]any{"name": "Ada", "place": "Langfuse"},
+			want:   "Hello Ada, welcome to Langfuse",
+		},
+		{
+			name:   "allows whitespace inside braces",
+			prompt: "Hello {{ name }} and {{\tother\t}}",
+			vars:   map[string]any{"name": "Ada", "other": "Bob"},
+			want:   "Hello Ada and Bob",
+		},
+		{
+			name:   "repeated variable",
+			prompt: "{{x}} + {{ x }}",
+			vars:   map[string]any{"x": "1"},
+			want:   "1 + 1",
+		},
+		{
+			name:   "formats non-string values",
+			prompt: "n={{n}} list={{list}} obj={{obj}}",
+			vars:   map[string]any{"n": 3, "list": []string{"a", "b"}, "obj": M{"k": "v"}},
+			want:   `n=3 list=["a","b"] obj={"k":"v"}`,
+		},
+		{
+			name:    "missing variable is kept as is",
+			prompt:  "Hello {{name}} from {{ city }}",
+			vars:    map[string]any{"name": "Ada"},
+			want:    "Hello Ada from {{ city }}",
+			wantErr: &PromptCompileError{Prompt: "greeting", MissingVariables: []string{"city"}},
+		},
+		{
+			name:    "unused variables are sorted",
+			prompt:  "Hello {{name}}",
+			vars:    map[string]any{"name": "Ada", "zeta": 1, "alpha": 2},
+			want:    "Hello Ada",
+			wantErr: &PromptCompileError{Prompt: "greeting", UnusedVariables: []string{"alpha", "zeta"}},
+		},
+		{
+			name:   "no variables",
+			prompt: "plain {text}",
+			want:   "plain {text}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &TextPrompt{PromptMeta: PromptMeta{Name: "greeting"}, Prompt: tt.prompt}
+			got, err := p.Compile(tt.vars)
+			if got != tt.want {
+				t.Errorf("Compile() = %q, want %q", got, tt.want)
+			}
+			assertCompileError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestChatPromptCompile(t *testing.T) {
+	history := []ChatMessage{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+	}
+	prompt := []ChatMessage{
+		{Type: ChatMessageTypeMessage, Role: "system", Content: "You are {{ persona }}"},
+		{Type: ChatMessageTypePlaceholder, Name: "history"},
+		{Role: "user", Content: "{{question}}"},
+	}
+
+	tests := []struct {
+		name         string
+		vars         map[string]any
+		placeholders map[string][]ChatMessage
+		want         ChatMessages
+		wantErr      *PromptCompileError
+	}{
+		{
+			name:         "expands placeholders and variables",
+			vars:         map[string]any{"persona": "a helper", "question": "why?"},
+			placeholders: map[string][]ChatMessage{"history": history},
+			want: ChatMessages{
+				{Type: ChatMessageTypeMessage, Role: "system", Content: "You are a helper"},
+				{Role: "user", Content: "hi"},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "why?"},
+			},
+		},
+		{
+			name:         "empty placeholder removes the slot",
+			vars:         map[string]any{"persona": "a helper", "question": "why?"},
+			placeholders: map[string][]ChatMessage{"history": nil},
+			want: ChatMessages{
+				{Type: ChatMessageTypeMessage, Role: "system", Content: "You are a helper"},
+				{Role: "user", Content: "why?"},
+			},
+		},
+		{
+			name: "missing placeholder",
+			vars: map[string]any{"persona": "a helper", "question": "why?"},
+			want: ChatMessages{
+				{Type: ChatMessageTypeMessage, Role: "system", Content: "You are a helper"},
+				{Role: "user", Content: "why?"},
+			},
+			wantErr: &PromptCompileError{Prompt: "chat", MissingPlaceholders: []string{"history"}},
+		},
+		{
+			name: "missing and unused variables and placeholders",
+			vars: map[string]any{"persona": "a helper", "extra": true},
+			placeholders: map[string][]ChatMessage{
+				"history": history,
+				"notes":   {{Role: "user", Content: "note"}},
+			},
+			want: ChatMessages{
+				{Type: ChatMessageTypeMessage, Role: "system", Content: "You are a helper"},
+				{Role: "user", Content: "hi"},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "{{question}}"},
+			},
+			wantErr: &PromptCompileError{
+				Prompt:             "chat",
+				MissingVariables:   []string{"question"},
+				UnusedVariables:    []string{"extra"},
+				UnusedPlaceholders: []string{"notes"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ChatPrompt{PromptMeta: PromptMeta{Name: "chat"}, Prompt: prompt}
+			got, err := p.Compile(tt.vars, tt.placeholders)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compile() = %+v, want %+v", got, tt.want)
+			}
+			assertCompileError(t, err, tt.wantErr)
+		})
+	}
+
+	if prompt[0].Content != "You are {{ persona }}" {
+		t.Errorf("Compile modified the prompt template: %q", prompt[0].Content)
+	}
+}
+
+func TestPromptCompileErrorMessage(t *testing.T) {
+	err := &PromptCompileError{
+		Prompt:              "chat",
+		MissingVariables:    []string{"a", "b"},
+		UnusedPlaceholders:  []string{"notes"},
+		MissingPlaceholders: []string{"history"},
+	}
+	want := `compile prompt "chat": missing variables a, b; missing placeholders history; unused placeholders notes`
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestChatMessagesToM(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages ChatMessages
+		want     []M
+	}{
+		{
+			name:     "empty",
+			messages: nil,
+			want:     []M{},
+		},
+		{
+			name: "keeps only role and content",
+			messages: ChatMessages{
+				{Type: ChatMessageTypeMessage, Role: "system", Content: "be brief"},
+				{Role: "user", Content: "hi", Name: "ignored"},
+			},
+			want: []M{
+				{"role": "system", "content": "be brief"},
+				{"role": "user", "content": "hi"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.messages.ToM(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToM() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertCompileError(t *testing.T, err error, want *PromptCompileError) {
+	t.Helper()
+	if want == nil {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	var got *PromptCompileError
+	if !errors.As(err, &got) {
+		t.Fatalf("error = %v, want *PromptCompileError", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("error = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package model
+
+// UsageDetails 灵活的用量明细，键为用量类型（如 input、output、input_cached_tokens），值为数量
+type UsageDetails map[string]int
+
+// CostDetails 灵活的费用明细，键与 UsageDetails 对应，值为费用（美元）
+type CostDetails map[string]float64
+
+// 常用的用量明细键
+const (
+	UsageKeyInput                   = "input"
+	UsageKeyOutput                  = "output"
+	UsageKeyTotal                   = "total"
+	UsageKeyInputCachedTokens       = "input_cached_tokens"
+	UsageKeyInputAudioTokens        = "input_audio_tokens"
+	UsageKeyInputCacheCreation      = "cache_creation_input_tokens"
+	UsageKeyInputCacheRead          = "cache_read_input_tokens"
+	UsageKeyInputToolUseTokens      = "input_tool_use_tokens"
+	UsageKeyOutputReasoningTokens   = "output_reasoning_tokens"
+	UsageKeyOutputAudioTokens       = "output_audio_tokens"
+	UsageKeyOutputAcceptedPredicted = "output_accepted_prediction_tokens"
+	UsageKeyOutputRejectedPredicted = "output_rejected_prediction_tokens"
+)
+
+// set 仅记录大于 0 的数量
+func (d UsageDetails) set(key string, value int) {
+	if value > 0 {
+		d[key] = value
+	}
+}
+
+// OpenAIUsage OpenAI Chat Completions 返回的 usage
+type OpenAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+		AudioTokens  int `json:"audio_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens          int `json:"reasoning_tokens"`
+		AudioTokens              int `json:"audio_tokens"`
+		AcceptedPredictionTokens int `json:"accepted_prediction_tokens"`
+		RejectedPredictionTokens int `json:"rejected_prediction_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// UsageDetailsFromOpenAI 转换 OpenAI usage，input/output 为扣除明细后的剩余部分，避免重复计费
+func UsageDetailsFromOpenAI(u OpenAIUsage) UsageDetails {
+	prompt := u.PromptTokensDetails
+	completion := u.CompletionTokensDetails
+
+	d := UsageDetails{}
+	d.set(UsageKeyInput, u.PromptTokens-prompt.CachedTokens-prompt.AudioTokens)
+	d.set(UsageKeyOutput, u.CompletionTokens-completion.ReasoningTokens-completion.AudioTokens-
+		completion.AcceptedPredictionTokens-completion.RejectedPredictionTokens)
+	d.set(UsageKeyTotal, u.TotalTokens)
+	d.set(UsageKeyInputCachedTokens, prompt.CachedTokens)
+	d.set(UsageKeyInputAudioTokens, prompt.AudioTokens)
+	d.set(UsageKeyOutputReasoningTokens, completion.ReasoningTokens)
+	d.set(UsageKeyOutputAudioTokens, completion.AudioTokens)
+	d.set(UsageKeyOutputAcceptedPredicted, completion.AcceptedPredictionTokens)
+	d.set(UsageKeyOutputRejectedPredicted, completion.RejectedPredictionTokens)
+	return d
+}
+
+// AnthropicUsage Anthropic Messages API 返回的 usage
+type AnthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// UsageDetailsFromAnthropic 转换 Anthropic usage，input_tokens 本身不包含缓存部分
+func UsageDetailsFromAnthropic(u AnthropicUsage) UsageDetails {
+	d := UsageDetails{}
+	d.set(UsageKeyInput, u.InputTokens)
+	d.set(UsageKeyOutput, u.OutputTokens)
+	d.set(UsageKeyInputCacheCreation, u.CacheCreationInputTokens)
+	d.set(UsageKeyInputCacheRead, u.CacheReadInputTokens)
+	return d
+}
+
+// GeminiUsage Gemini generateContent 返回的 usageMetadata
+type GeminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	ToolUsePromptTokenCount int `json:"toolUsePromptTokenCount"`
+}
+
+// UsageDetailsFromGemini 转换 Gemini usageMetadata，input 为扣除缓存后的剩余部分
+func UsageDetailsFromGemini(u GeminiUsage) UsageDetails {
+	d := UsageDetails{}
+	d.set(UsageKeyInput, u.PromptTokenCount-u.CachedContentTokenCount)
+	d.set(UsageKeyOutput, u.CandidatesTokenCount)
+	d.set(UsageKeyTotal, u.TotalTokenCount)
+	d.set(UsageKeyInputCachedTokens, u.CachedContentTokenCount)
+	d.set(UsageKeyInputToolUseTokens, u.ToolUsePromptTokenCount)
+	d.set(UsageKeyOutputReasoningTokens, u.ThoughtsTokenCount)
+	return d
+}
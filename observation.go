@@ -0,0 +1,143 @@
+package langfuse
+
+import (
+	"fmt"
+
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// observable 包含 observation 通用字段的对象
+type observable interface {
+	Base() *model.ObservationBody
+}
+
+// observe 补全 trace、ID 与父节点后发送 create 事件
+func (l *Langfuse) observe(eventType model.IngestionEventType, body observable, parentID *string) error {
+	base := body.Base()
+	if base.TraceID == "" {
+		traceID, err := l.createTrace(base.Name)
+		if err != nil {
+			return err
+		}
+
+		base.TraceID = traceID
+	}
+
+	base.ID = l.buildID(&base.ID)
+
+	if parentID != nil {
+		base.ParentObservationID = *parentID
+	}
+	return l.dispatch(
+		model.IngestionEvent{
+			ID:        l.buildID(nil),
+			Type:      eventType,
+			Timestamp: l.now(),
+			Body:      body,
+		},
+		applyDefaults,
+	)
+}
+
+// Agent 构建智能体
+func (l *Langfuse) Agent(a *model.Agent, parentID *string) (*model.Agent, error) {
+	if err := l.observe(model.IngestionEventTypeAgentCreate, a, parentID); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Tool 构建工具调用
+func (l *Langfuse) Tool(t *model.Tool, parentID *string) (*model.Tool, error) {
+	if err := l.observe(model.IngestionEventTypeToolCreate, t, parentID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Chain 构建链
+func (l *Langfuse) Chain(c *model.Chain, parentID *string) (*model.Chain, error) {
+	if err := l.observe(model.IngestionEventTypeChainCreate, c, parentID); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Retriever 构建检索
+func (l *Langfuse) Retriever(r *model.Retriever, parentID *string) (*model.Retriever, error) {
+	if err := l.observe(model.IngestionEventTypeRetrieverCreate, r, parentID); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Evaluator 构建评估
+func (l *Langfuse) Evaluator(e *model.Evaluator, parentID *string) (*model.Evaluator, error) {
+	if err := l.observe(model.IngestionEventTypeEvaluatorCreate, e, parentID); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Embedding 构建向量化
+func (l *Langfuse) Embedding(e *model.Embedding, parentID *string) (*model.Embedding, error) {
+	if err := l.observe(model.IngestionEventTypeEmbeddingCreate, e, parentID); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Guardrail 构建安全护栏
+func (l *Langfuse) Guardrail(g *model.Guardrail, parentID *string) (*model.Guardrail, error) {
+	if err := l.observe(model.IngestionEventTypeGuardrailCreate, g, parentID); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Observation 通过 observation-create 构建任意类型的 observation，用于 SDK 尚未单独支持的类型
+func (l *Langfuse) Observation(o *model.Observation, parentID *string) (*model.Observation, error) {
+	if o.Type == "" {
+		return nil, fmt.Errorf("observation type is required")
+	}
+	if err := l.observe(model.IngestionEventTypeObservationCreate, o, parentID); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ObservationUpdate 通过 observation-update 更新任意类型的 observation
+func (l *Langfuse) ObservationUpdate(o *model.Observation) (*model.Observation, error) {
+	if o.ID == "" {
+		return nil, fmt.Errorf("observation ID is required")
+	}
+
+	if o.TraceID == "" {
+		return nil, fmt.Errorf("trace ID is required")
+	}
+
+	if o.Type == "" {
+		return nil, fmt.Errorf("observation type is required")
+	}
+	if err := l.dispatch(
+		model.IngestionEvent{
+			ID:        l.buildID(nil),
+			Type:      model.IngestionEventTypeObservationUpdate,
+			Timestamp: l.now(),
+			Body:      o,
+		},
+		skipDefaults,
+	); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ObservationEnd 结束任意类型的 observation，未设置 EndTime 时使用当前时间
+func (l *Langfuse) ObservationEnd(o *model.Observation) (*model.Observation, error) {
+	if o.EndTime == nil {
+		now := l.now()
+		o.EndTime = &now
+	}
+	return l.ObservationUpdate(o)
+}
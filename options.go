@@ -0,0 +1,310 @@
+package langfuse
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+)
+
+// Compression 上报请求体的压缩方式
+type Compression string
+
+const (
+	// CompressionNone 不压缩
+	CompressionNone Compression = ""
+	// CompressionGzip gzip 压缩
+	CompressionGzip Compression = api.EncodingGzip
+	// CompressionZstd zstd 压缩
+	CompressionZstd Compression = api.EncodingZstd
+)
+
+// supported 是否为支持的压缩方式
+func (c Compression) supported() bool {
+	switch c {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return true
+	default:
+		return false
+	}
+}
+
+// Option Langfuse 配置项
+type Option func(*config)
+
+type config struct {
+	host          string
+	publicKey     string
+	secretKey     string
+	parallel      int
+	flushInterval time.Duration
+	queueSize     int64
+	batchSize     int
+	retryPolicy   RetryPolicy
+	dropHandler   DropHandler
+	location      *time.Location
+	timezone      string
+	clock         Clock
+	httpClient    *http.Client
+
+	requestsPerSecond float64
+	bytesPerSecond    int
+
+	spool        *SpoolConfig
+	backpressure BackpressureConfig
+
+	compression          Compression
+	compressionThreshold int
+
+	repanic           bool
+	panicFlushTimeout time.Duration
+
+	defaults defaults
+
+	idGenerator      IDGenerator
+	traceIDGenerator IDGenerator
+
+	promptCacheTTL time.Duration
+	promptWarmups  []promptWarmup
+}
+
+func defaultConfig() config {
+	return config{
+		parallel:      defaultParallel,
+		flushInterval: defaultFlushInterval,
+		batchSize:     batchSize,
+		retryPolicy:   DefaultRetryPolicy(),
+		location:      time.UTC,
+		clock:         SystemClock{},
+
+		idGenerator: UUIDv4Generator{},
+
+		promptCacheTTL: defaultPromptCacheTTL,
+
+		repanic:           true,
+		panicFlushTimeout: defaultPanicFlushTimeout,
+	}
+}
+
+// WithHost 设置 Langfuse 服务地址，未设置时读取 LANGFUSE_HOST
+func WithHost(host string) Option {
+	return func(c *config) {
+		c.host = host
+	}
+}
+
+// WithPublicKey 设置公钥，未设置时读取 LANGFUSE_PUBLIC_KEY
+func WithPublicKey(publicKey string) Option {
+	return func(c *config) {
+		c.publicKey = publicKey
+	}
+}
+
+// WithSecretKey 设置私钥，未设置时读取 LANGFUSE_SECRET_KEY
+func WithSecretKey(secretKey string) Option {
+	return func(c *config) {
+		c.secretKey = secretKey
+	}
+}
+
+// WithParallel 设置批量推送的并发数
+func WithParallel(parallel int) Option {
+	return func(c *config) {
+		if parallel > 0 {
+			c.parallel = parallel
+		}
+	}
+}
+
+// WithFlushInterval 设置定时发送的周期
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.flushInterval = d
+		}
+	}
+}
+
+// WithQueueSize 设置内存队列的最大字节数
+func WithQueueSize(size int64) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.queueSize = size
+		}
+	}
+}
+
+// WithBatchSize 设置单次请求的最大字节数
+func WithBatchSize(size int) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.batchSize = size
+		}
+	}
+}
+
+// WithLocation 设置事件时间使用的时区，默认 UTC
+func WithLocation(loc *time.Location) Option {
+	return func(c *config) {
+		if loc != nil {
+			c.location = loc
+			c.timezone = ""
+		}
+	}
+}
+
+// WithTimezone 按 IANA 名称（如 Asia/Shanghai）设置事件时间使用的时区，名称无效时记录警告并使用 UTC
+func WithTimezone(name string) Option {
+	return func(c *config) {
+		c.timezone = name
+	}
+}
+
+// WithClock 设置时间来源，用于测试中生成确定的时间
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// WithIDGenerator 设置 observation、score 与事件 ID 的生成方式，默认 UUIDv4；未单独设置 trace ID 生成方式时同样用于 trace
+func WithIDGenerator(generator IDGenerator) Option {
+	return func(c *config) {
+		if generator != nil {
+			c.idGenerator = generator
+		}
+	}
+}
+
+// WithTraceIDGenerator 单独设置 trace ID 的生成方式，如 W3CTraceIDGenerator
+func WithTraceIDGenerator(generator IDGenerator) Option {
+	return func(c *config) {
+		if generator != nil {
+			c.traceIDGenerator = generator
+		}
+	}
+}
+
+// WithHTTPClient 设置底层使用的 http.Client
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithRetryPolicy 设置批量发送失败后的重试策略
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithDropHandler 设置事件被最终丢弃时的回调
+func WithDropHandler(handler DropHandler) Option {
+	return func(c *config) {
+		c.dropHandler = handler
+	}
+}
+
+// WithRateLimit 设置客户端限流，requestsPerSecond 为每秒请求数，bytesPerSecond 为每秒发送字节数，小于等于 0 表示不限制
+func WithRateLimit(requestsPerSecond float64, bytesPerSecond int) Option {
+	return func(c *config) {
+		c.requestsPerSecond = requestsPerSecond
+		c.bytesPerSecond = bytesPerSecond
+	}
+}
+
+// WithSpool 开启磁盘 spool，进程崩溃或重启后可回放未发送的事件
+func WithSpool(spool SpoolConfig) Option {
+	return func(c *config) {
+		c.spool = &spool
+	}
+}
+
+// WithBackpressure 设置队列满时的背压策略
+func WithBackpressure(backpressure BackpressureConfig) Option {
+	return func(c *config) {
+		c.backpressure = backpressure
+	}
+}
+
+// WithCompression 开启上报请求体压缩，小于 threshold 字节的请求不压缩，threshold 小于等于 0 时使用默认值 1KB；
+// 不支持的压缩方式在创建时告警一次并退化为不压缩
+func WithCompression(compression Compression, threshold int) Option {
+	return func(c *config) {
+		c.compression = compression
+		c.compressionThreshold = threshold
+	}
+}
+
+// WithRepanic 设置 WithSpan 与 Recover 捕获 panic 后是否重新 panic，默认 true
+func WithRepanic(repanic bool) Option {
+	return func(c *config) {
+		c.repanic = repanic
+	}
+}
+
+// WithPanicFlushTimeout 设置捕获 panic 后同步发送事件的最长等待时间
+func WithPanicFlushTimeout(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.panicFlushTimeout = d
+		}
+	}
+}
+
+// WithEnvironment 设置默认的 environment（如 production、staging），未设置时读取 LANGFUSE_TRACING_ENVIRONMENT
+func WithEnvironment(environment string) Option {
+	return func(c *config) {
+		c.defaults.environment = environment
+	}
+}
+
+// WithRelease 设置 trace 默认的 release，未设置时读取 LANGFUSE_RELEASE
+func WithRelease(release string) Option {
+	return func(c *config) {
+		c.defaults.release = release
+	}
+}
+
+// WithVersion 设置 trace 与 observation 默认的 version
+func WithVersion(version string) Option {
+	return func(c *config) {
+		c.defaults.version = version
+	}
+}
+
+// WithDefaultTags 设置 trace 默认的标签，与调用方设置的标签合并
+func WithDefaultTags(tags ...string) Option {
+	return func(c *config) {
+		c.defaults.tags = append(c.defaults.tags, tags...)
+	}
+}
+
+// WithDefaultMetadata 设置 trace 与 observation 默认的 metadata，调用方设置的同名键优先
+func WithDefaultMetadata(metadata map[string]any) Option {
+	return func(c *config) {
+		if c.defaults.metadata == nil {
+			c.defaults.metadata = make(map[string]any, len(metadata))
+		}
+		for k, v := range metadata {
+			c.defaults.metadata[k] = v
+		}
+	}
+}
+
+// WithPromptCacheTTL 设置 prompt 缓存的有效期，默认 60 秒，小于等于 0 时不缓存
+func WithPromptCacheTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.promptCacheTTL = ttl
+	}
+}
+
+// WithPromptWarmup 启动时在后台预先加载 prompt，可多次调用
+func WithPromptWarmup(name string, opts ...PromptOption) Option {
+	return func(c *config) {
+		c.promptWarmups = append(c.promptWarmups, promptWarmup{name: name, opts: opts})
+	}
+}
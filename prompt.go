@@ -0,0 +1,140 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+var (
+	// ErrPromptNotFound 服务端不存在指定名称、版本或标签的 prompt
+	ErrPromptNotFound = errors.New("langfuse: prompt not found")
+	// ErrPromptType prompt 类型与期望的不一致
+	ErrPromptType = errors.New("langfuse: unexpected prompt type")
+)
+
+// PromptOption 获取 prompt 的选项
+type PromptOption func(*promptOptions)
+
+type promptOptions struct {
+	version  int
+	label    string
+	fallback model.Prompt
+}
+
+// WithPromptVersion 获取指定版本的 prompt
+func WithPromptVersion(version int) PromptOption {
+	return func(o *promptOptions) {
+		o.version = version
+	}
+}
+
+// WithPromptLabel 获取指定标签（如 production、staging）的 prompt，默认 production
+func WithPromptLabel(label string) PromptOption {
+	return func(o *promptOptions) {
+		o.label = label
+	}
+}
+
+// WithPromptFallback 服务端不可达或返回错误状态码且没有缓存时返回的 prompt（*model.TextPrompt 或 *model.ChatPrompt），返回值的 IsFallback 为 true
+func WithPromptFallback(prompt model.Prompt) PromptOption {
+	return func(o *promptOptions) {
+		o.fallback = prompt
+	}
+}
+
+// GetPrompt 按名称获取 prompt，返回 *model.TextPrompt 或 *model.ChatPrompt；版本与标签不能同时指定。
+// 结果在 TTL 内使用缓存，过期后先返回旧值并在后台刷新
+func (l *Langfuse) GetPrompt(ctx context.Context, name string, opts ...PromptOption) (model.Prompt, error) {
+	o := promptOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return l.cachedPrompt(ctx, name, o)
+}
+
+// GetTextPrompt 获取文本 prompt，类型不是 text 时返回 ErrPromptType
+func (l *Langfuse) GetTextPrompt(ctx context.Context, name string, opts ...PromptOption) (*model.TextPrompt, error) {
+	p, err := l.GetPrompt(ctx, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	text, ok := p.(*model.TextPrompt)
+	if !ok {
+		return nil, fmt.Errorf("%w: prompt %q is %s, not text", ErrPromptType, name, p.Meta().Type)
+	}
+	return text, nil
+}
+
+// GetChatPrompt 获取对话 prompt，类型不是 chat 时返回 ErrPromptType
+func (l *Langfuse) GetChatPrompt(ctx context.Context, name string, opts ...PromptOption) (*model.ChatPrompt, error) {
+	p, err := l.GetPrompt(ctx, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	chat, ok := p.(*model.ChatPrompt)
+	if !ok {
+		return nil, fmt.Errorf("%w: prompt %q is %s, not chat", ErrPromptType, name, p.Meta().Type)
+	}
+	return chat, nil
+}
+
+// fetchPrompt 从服务端获取 prompt，404 转换为 ErrPromptNotFound
+func (l *Langfuse) fetchPrompt(ctx context.Context, name string, o promptOptions) (model.Prompt, error) {
+	res := api.PromptResponse{}
+	err := l.client.GetPrompt(ctx, &api.GetPrompt{Name: name, Version: o.version, Label: o.label}, &res)
+	if err != nil {
+		var statusErr *api.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, o.describe(name))
+		}
+		return nil, err
+	}
+	return res.Prompt, nil
+}
+
+// describe 用于错误信息的 prompt 描述
+func (o promptOptions) describe(name string) string {
+	switch {
+	case o.version > 0:
+		return fmt.Sprintf("%s (version %d)", name, o.version)
+	case o.label != "":
+		return fmt.Sprintf("%s (label %s)", name, o.label)
+	default:
+		return name
+	}
+}
+
+// linkPrompt 用 g.Prompt 填充未设置的 PromptName 与 PromptVersion，fallback prompt 在服务端不存在，不做关联
+func linkPrompt(g *model.Generation) {
+	if g.PromptName != "" {
+		return
+	}
+	meta, ok := promptMeta(g.Prompt)
+	if !ok || meta.IsFallback {
+		return
+	}
+	g.PromptName = meta.Name
+	g.PromptVersion = meta.Version
+}
+
+// promptMeta 返回 prompt 的版本信息，prompt 为 nil（包括 nil 指针）时返回 false
+func promptMeta(prompt model.Prompt) (model.PromptMeta, bool) {
+	switch p := prompt.(type) {
+	case nil:
+		return model.PromptMeta{}, false
+	case *model.TextPrompt:
+		if p == nil {
+			return model.PromptMeta{}, false
+		}
+	case *model.ChatPrompt:
+		if p == nil {
+			return model.PromptMeta{}, false
+		}
+	}
+	return prompt.Meta(), true
+}
@@ -0,0 +1,227 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+const (
+	// defaultPromptCacheTTL prompt 缓存的默认有效期
+	defaultPromptCacheTTL = 60 * time.Second
+	// promptRefreshTimeout 后台刷新 prompt 的超时时间
+	promptRefreshTimeout = 10 * time.Second
+	// defaultPromptLabel 未指定版本与标签时服务端返回的标签
+	defaultPromptLabel = "production"
+)
+
+// promptCache 进程内的 prompt 缓存，过期后先返回旧值并在后台刷新
+type promptCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]map[string]*promptCacheEntry // name -> 版本或标签 -> 缓存
+	// generations name -> invalidate 次数，获取前记录，写入时不一致说明期间已 invalidate，丢弃获取到的旧值
+	generations map[string]uint64
+}
+
+// promptCacheKey prompt 名称及其版本或标签
+type promptCacheKey struct {
+	name     string
+	selector string
+}
+
+type promptCacheEntry struct {
+	prompt     model.Prompt
+	expiresAt  time.Time
+	refreshing bool
+}
+
+// promptWarmup 启动时预先加载的 prompt
+type promptWarmup struct {
+	name string
+	opts []PromptOption
+}
+
+// key 缓存键，未指定版本与标签时等同于 production 标签
+func (o promptOptions) key(name string) promptCacheKey {
+	switch {
+	case o.version > 0:
+		return promptCacheKey{name: name, selector: "version:" + strconv.Itoa(o.version)}
+	case o.label != "":
+		return promptCacheKey{name: name, selector: "label:" + o.label}
+	default:
+		return promptCacheKey{name: name, selector: "label:" + defaultPromptLabel}
+	}
+}
+
+// enabled ttl 小于等于 0 时不缓存
+func (c *promptCache) enabled() bool {
+	return c.ttl > 0
+}
+
+// get 返回缓存的 prompt；已过期且没有正在进行的刷新时 refresh 为 true，调用方负责刷新
+func (c *promptCache) get(key promptCacheKey, now time.Time) (prompt model.Prompt, refresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key.name][key.selector]
+	if !ok {
+		return nil, false
+	}
+	if now.Before(entry.expiresAt) || entry.refreshing {
+		return entry.prompt, false
+	}
+	entry.refreshing = true
+	return entry.prompt, true
+}
+
+// generation 返回名称当前的版本号，获取 prompt 前调用并在 set 时传入
+func (c *promptCache) generation(name string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[name]
+}
+
+// set 写入缓存，gen 与当前版本号不一致（获取期间被 invalidate）时丢弃
+func (c *promptCache) set(key promptCacheKey, gen uint64, prompt model.Prompt, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[key.name] != gen {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]map[string]*promptCacheEntry)
+	}
+	if c.entries[key.name] == nil {
+		c.entries[key.name] = make(map[string]*promptCacheEntry)
+	}
+	c.entries[key.name][key.selector] = &promptCacheEntry{prompt: prompt, expiresAt: now.Add(c.ttl)}
+}
+
+// refreshFailed 刷新失败时保留旧值，下次访问再重试
+func (c *promptCache) refreshFailed(key promptCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key.name][key.selector]; ok {
+		entry.refreshing = false
+	}
+}
+
+// cachedPrompt 优先返回缓存，过期时返回旧值并在后台刷新；没有缓存且获取失败时使用 fallback
+func (l *Langfuse) cachedPrompt(ctx context.Context, name string, o promptOptions) (model.Prompt, error) {
+	if !l.prompts.enabled() {
+		return l.fetchPromptOrFallback(ctx, name, o)
+	}
+
+	key := o.key(name)
+	gen := l.prompts.generation(name)
+	if prompt, refresh := l.prompts.get(key, l.clock.Now()); prompt != nil {
+		if refresh {
+			go l.refreshPrompt(context.WithoutCancel(ctx), key, gen, name, o)
+		}
+		return prompt, nil
+	}
+
+	prompt, err := l.fetchPrompt(ctx, name, o)
+	if err != nil {
+		return promptFallback(name, o, err)
+	}
+	l.prompts.set(key, gen, prompt, l.clock.Now())
+	return prompt, nil
+}
+
+// refreshPrompt 后台刷新过期的 prompt
+func (l *Langfuse) refreshPrompt(ctx context.Context, key promptCacheKey, gen uint64, name string, o promptOptions) {
+	ctx, cancel := context.WithTimeout(ctx, promptRefreshTimeout)
+	defer cancel()
+
+	prompt, err := l.fetchPrompt(ctx, name, o)
+	if err != nil {
+		log.Warnf(ctx, "refresh prompt %s error, keep serving cached version: %s", o.describe(name), err.Error())
+		l.prompts.refreshFailed(key)
+		return
+	}
+	l.prompts.set(key, gen, prompt, l.clock.Now())
+}
+
+func (l *Langfuse) fetchPromptOrFallback(ctx context.Context, name string, o promptOptions) (model.Prompt, error) {
+	prompt, err := l.fetchPrompt(ctx, name, o)
+	if err != nil {
+		return promptFallback(name, o, err)
+	}
+	return prompt, nil
+}
+
+// promptFallback 服务端不可达或返回错误状态码时返回调用方提供的 fallback（标记 IsFallback），
+// 未提供 fallback 或为调用方参数错误等其他错误时返回原错误
+func promptFallback(name string, o promptOptions, err error) (model.Prompt, error) {
+	if !fallbackable(err) {
+		return nil, err
+	}
+	switch p := o.fallback.(type) {
+	case *model.TextPrompt:
+		fallback := *p
+		fallback.PromptMeta = fallbackMeta(fallback.PromptMeta, name, model.PromptTypeText)
+		return &fallback, nil
+	case *model.ChatPrompt:
+		fallback := *p
+		fallback.PromptMeta = fallbackMeta(fallback.PromptMeta, name, model.PromptTypeChat)
+		return &fallback, nil
+	}
+	return nil, err
+}
+
+// fallbackable 是否为可以使用 fallback 的错误：网络错误或服务端返回的错误状态码（包括 ErrPromptNotFound）
+func fallbackable(err error) bool {
+	var statusErr *api.StatusError
+	return api.IsNetworkError(err) || errors.As(err, &statusErr) || errors.Is(err, ErrPromptNotFound)
+}
+
+func fallbackMeta(meta model.PromptMeta, name string, promptType model.PromptType) model.PromptMeta {
+	if meta.Name == "" {
+		meta.Name = name
+	}
+	meta.Type = promptType
+	meta.IsFallback = true
+	return meta
+}
+
+// warmupPrompts 在后台加载启动时配置的 prompt，失败只记录日志
+func (l *Langfuse) warmupPrompts(ctx context.Context, warmups []promptWarmup) {
+	if len(warmups) == 0 || !l.prompts.enabled() {
+		return
+	}
+	go func() {
+		for _, w := range warmups {
+			o := promptOptions{}
+			for _, opt := range w.opts {
+				opt(&o)
+			}
+			gen := l.prompts.generation(w.name)
+			fetchCtx, cancel := context.WithTimeout(ctx, promptRefreshTimeout)
+			prompt, err := l.fetchPrompt(fetchCtx, w.name, o)
+			cancel()
+			if err != nil {
+				log.Warnf(ctx, "warm up prompt %s error: %s", o.describe(w.name), err.Error())
+				continue
+			}
+			l.prompts.set(o.key(w.name), gen, prompt, l.clock.Now())
+		}
+	}()
+}
+
+// invalidate 删除某个名称下所有版本与标签的缓存，并使进行中的获取结果不再写入缓存，创建新版本或修改标签后调用
+func (c *promptCache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+	if c.generations == nil {
+		c.generations = make(map[string]uint64)
+	}
+	c.generations[name]++
+}
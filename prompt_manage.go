@@ -0,0 +1,102 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// PromptListQuery 列出 prompt 的过滤与分页条件，零值表示不过滤
+type PromptListQuery struct {
+	Name          string
+	Label         string
+	Tag           string
+	Page          int
+	Limit         int
+	FromUpdatedAt *time.Time
+	ToUpdatedAt   *time.Time
+}
+
+// CreatePrompt 创建 prompt 的新版本（名称不存在时创建 prompt），prompt 为 *model.TextPrompt 或 *model.ChatPrompt，
+// 使用其中的 Name、Prompt、Config、Labels、Tags 与 CommitMessage，返回服务端分配了版本号的 prompt
+func (l *Langfuse) CreatePrompt(ctx context.Context, prompt model.Prompt) (model.Prompt, error) {
+	meta, ok := promptMeta(prompt)
+	if !ok {
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	req := api.CreatePrompt{
+		Name:          meta.Name,
+		Config:        meta.Config,
+		Labels:        meta.Labels,
+		Tags:          meta.Tags,
+		CommitMessage: meta.CommitMessage,
+	}
+	switch p := prompt.(type) {
+	case *model.TextPrompt:
+		req.Type = model.PromptTypeText
+		req.Prompt = p.Prompt
+	case *model.ChatPrompt:
+		req.Type = model.PromptTypeChat
+		req.Prompt = p.Prompt
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrPromptType, prompt)
+	}
+
+	res := api.PromptResponse{}
+	if err := l.client.CreatePrompt(ctx, &req, &res); err != nil {
+		return nil, err
+	}
+	l.prompts.invalidate(meta.Name)
+	return res.Prompt, nil
+}
+
+// UpdatePromptLabels 设置某个版本的标签，如传入 production 将该版本设为生产版本，同名标签会从其他版本上移除
+func (l *Langfuse) UpdatePromptLabels(ctx context.Context, name string, version int, labels ...string) (model.Prompt, error) {
+	res := api.PromptResponse{}
+	err := l.client.UpdatePromptLabels(ctx, &api.UpdatePromptLabels{Name: name, Version: version, NewLabels: labels}, &res)
+	if err != nil {
+		return nil, err
+	}
+	l.prompts.invalidate(name)
+	return res.Prompt, nil
+}
+
+// ListPrompts 分页列出 prompt 及其所有版本号
+func (l *Langfuse) ListPrompts(ctx context.Context, query PromptListQuery) (*model.PromptList, error) {
+	res := api.ListPromptsResponse{}
+	err := l.client.ListPrompts(ctx, &api.ListPrompts{
+		Name:          query.Name,
+		Label:         query.Label,
+		Tag:           query.Tag,
+		Page:          query.Page,
+		Limit:         query.Limit,
+		FromUpdatedAt: query.FromUpdatedAt,
+		ToUpdatedAt:   query.ToUpdatedAt,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res.PromptList, nil
+}
+
+// PromptVersions 返回某个 prompt 的所有版本号（升序），不存在时返回 ErrPromptNotFound
+func (l *Langfuse) PromptVersions(ctx context.Context, name string) ([]int, error) {
+	list, err := l.ListPrompts(ctx, PromptListQuery{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range list.Data {
+		if summary.Name != name {
+			continue
+		}
+		versions := append([]int(nil), summary.Versions...)
+		sort.Ints(versions)
+		return versions, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+}
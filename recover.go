@@ -0,0 +1,87 @@
+package langfuse
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+const defaultPanicFlushTimeout = 5 * time.Second
+
+// PanicError 被捕获的 panic 及其调用栈
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// WithSpan 在名为 name 的 span 中执行 fn，fn 返回错误或 panic 时以 ERROR 级别结束 span，
+// panic 时会同步 Flush，并按 WithRepanic 配置重新 panic 或以 *PanicError 返回
+func (l *Langfuse) WithSpan(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	spanCtx, span, errStart := l.StartSpan(ctx, &model.Span{Name: name})
+	if errStart != nil {
+		// 无法记录时不影响业务逻辑
+		return fn(ctx)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = l.onPanic(r, func(perr error) { span.RecordError(perr) }, span.End)
+			if l.repanic {
+				panic(r)
+			}
+		}
+	}()
+
+	err = fn(spanCtx)
+	span.RecordError(err)
+	_ = span.End()
+	return err
+}
+
+// Recover 捕获 panic 并以 ERROR 级别结束 span，需直接用于 defer：defer span.Recover()
+func (s *Span) Recover() {
+	if s == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		s.l.onPanic(r, func(err error) { s.RecordError(err) }, s.End)
+		if s.l.repanic {
+			panic(r)
+		}
+	}
+}
+
+// Recover 捕获 panic 并以 ERROR 级别结束 generation，需直接用于 defer：defer generation.Recover()
+func (g *Generation) Recover() {
+	if g == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		g.l.onPanic(r, func(err error) { g.RecordError(err) }, g.End)
+		if g.l.repanic {
+			panic(r)
+		}
+	}
+}
+
+// onPanic 记录 panic、结束 observation 并同步发送队列中的事件，避免进程退出时丢失
+func (l *Langfuse) onPanic(r any, record func(err error), end func(opts ...EndOption) error) error {
+	perr := &PanicError{Value: r, Stack: debug.Stack()}
+	record(perr)
+	_ = end()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.panicFlushTimeout)
+	defer cancel()
+	if err := l.Flush(ctx); err != nil {
+		log.Errorf(ctx, "flush on panic error: %s", err.Error())
+	}
+	return perr
+}
@@ -0,0 +1,212 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 30 * time.Second
+	defaultJitter      = 0.2
+)
+
+// RetryPolicy 批量发送失败后的重试策略
+type RetryPolicy struct {
+	// MaxAttempts 单个事件最多发送次数（含首次），小于等于 1 时不重试
+	MaxAttempts int
+	// BaseDelay 首次重试前的等待时间，之后按指数增长
+	BaseDelay time.Duration
+	// MaxDelay 单次等待时间上限
+	MaxDelay time.Duration
+	// Jitter 随机抖动比例，取值 0~1
+	Jitter float64
+	// RetryableStatusCodes 可重试的 HTTP 状态码，网络错误总是可重试
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: defaultMaxAttempts,
+		BaseDelay:   defaultBaseDelay,
+		MaxDelay:    defaultMaxDelay,
+		Jitter:      defaultJitter,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// retryable 判断错误是否可重试
+func (p RetryPolicy) retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return p.retryableStatus(statusErr.StatusCode)
+	}
+	return api.IsNetworkError(err)
+}
+
+// retryableStatus 判断状态码是否可重试
+func (p RetryPolicy) retryableStatus(code int) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// exhausted 判断已失败次数是否达到上限
+func (p RetryPolicy) exhausted(failCount int) bool {
+	return failCount >= p.MaxAttempts
+}
+
+// backoff 计算第 attempt 次重试前的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 || attempt <= 0 {
+		return 0
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		//nolint:gosec // 抖动不需要安全随机数
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// failedEvent 发送失败的事件及原因
+type failedEvent struct {
+	env envelope
+	err error
+}
+
+// retryFailed 整批发送失败时，根据重试策略筛选需要重新入队的事件
+func (l *Langfuse) retryFailed(ctx context.Context, batch []envelope, err error) []envelope {
+	failed := make([]failedEvent, 0, len(batch))
+	for _, env := range batch {
+		failed = append(failed, failedEvent{env: env, err: err})
+	}
+	if !l.retryPolicy.retryable(err) {
+		l.drop(ctx, failed)
+		return nil
+	}
+	return l.requeue(ctx, failed)
+}
+
+// retryPartial 处理 207 响应中逐个事件的错误：5xx/429 重试，其余 4xx 丢弃，成功的事件直接确认
+func (l *Langfuse) retryPartial(ctx context.Context, batch []envelope, errs []api.Error) []envelope {
+	if len(errs) == 0 {
+		l.ack(batch)
+		return nil
+	}
+	errByID := make(map[string]api.Error, len(errs))
+	for _, e := range errs {
+		errByID[e.ID] = e
+	}
+
+	var retry, drop []failedEvent
+	succeeded := make([]envelope, 0, len(batch))
+	for _, env := range batch {
+		e, ok := errByID[env.event.ID]
+		if !ok {
+			succeeded = append(succeeded, env)
+			continue
+		}
+		err := &IngestionError{EventID: e.ID, Status: e.Status, Message: e.Message}
+		if e.Status >= http.StatusInternalServerError || l.retryPolicy.retryableStatus(e.Status) {
+			retry = append(retry, failedEvent{env: env, err: err})
+		} else {
+			drop = append(drop, failedEvent{env: env, err: err})
+		}
+	}
+	l.ack(succeeded)
+	l.drop(ctx, drop)
+	return l.requeue(ctx, retry)
+}
+
+// requeue 增加失败次数，丢弃超过上限的事件，并按退避时间设置最早发送时间，不在发送协程中等待
+func (l *Langfuse) requeue(ctx context.Context, failed []failedEvent) []envelope {
+	if len(failed) == 0 {
+		return nil
+	}
+
+	retry := make([]envelope, 0, len(failed))
+	var exhausted []failedEvent
+	now := time.Now()
+	for _, f := range failed {
+		f.env.event.FailCount++
+		if l.retryPolicy.exhausted(f.env.event.FailCount) {
+			exhausted = append(exhausted, f)
+			continue
+		}
+		f.env.notBefore = now.Add(l.retryPolicy.backoff(f.env.event.FailCount))
+		retry = append(retry, f.env)
+	}
+	l.drop(ctx, exhausted)
+	if len(retry) == 0 {
+		return nil
+	}
+
+	l.retried.Add(uint64(len(retry)))
+	log.Warnf(ctx, "ingest error, retry %d events: %s", len(retry), failed[0].err.Error())
+	return retry
+}
+
+// due 将事件分为已到发送时间与仍在退避中的两部分
+func due(events []envelope, now time.Time) (ready, deferred []envelope) {
+	for _, env := range events {
+		if env.notBefore.After(now) {
+			deferred = append(deferred, env)
+			continue
+		}
+		ready = append(ready, env)
+	}
+	return ready, deferred
+}
+
+// drop 丢弃事件并通知调用方
+func (l *Langfuse) drop(ctx context.Context, failed []failedEvent) {
+	for _, f := range failed {
+		log.Errorf(ctx, "ingest error, drop event %s (fail count %d): %s", f.env.event.ID, f.env.event.FailCount, f.err.Error())
+		for _, env := range f.env.all() {
+			l.dropped.Add(1)
+			l.ackSpool(env)
+			if l.dropHandler != nil {
+				l.dropHandler(env.event, f.err)
+			}
+			env.release()
+		}
+	}
+}
+
+// ack 确认事件已发送成功
+func (l *Langfuse) ack(envs []envelope) {
+	for _, env := range envs {
+		for _, e := range env.all() {
+			l.sent.Add(1)
+			l.ackSpool(e)
+			e.release()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// ErrInvalidScore 分数不符合数据类型或分数配置
+var ErrInvalidScore = errors.New("langfuse: invalid score")
+
+const (
+	// scoreConfigFetchTimeout 获取分数配置的超时时间，避免服务端无响应时阻塞调用方
+	scoreConfigFetchTimeout = 5 * time.Second
+	// scoreConfigFailureTTL 获取失败的结果缓存时间，期间同一配置不再请求服务端
+	scoreConfigFailureTTL = 30 * time.Second
+)
+
+// scoreConfigCache 按 ID 缓存的分数配置，获取失败的结果短时间缓存
+type scoreConfigCache struct {
+	mu       sync.RWMutex
+	configs  map[string]*model.ScoreConfig
+	failures map[string]scoreConfigFailure
+}
+
+type scoreConfigFailure struct {
+	err   error
+	until time.Time
+}
+
+// ScoreWithContext 构建分数，设置了 ConfigID 时先获取分数配置（带缓存，最多等待 5 秒）并校验，不合法时返回 ErrInvalidScore；
+// 无法获取配置时跳过客户端校验
+func (l *Langfuse) ScoreWithContext(ctx context.Context, s *model.Score) (*model.Score, error) {
+	if s.TraceID == "" && s.SessionID == "" && s.DatasetRunID == "" {
+		return nil, fmt.Errorf("trace ID, session ID or dataset run ID is required")
+	}
+
+	if s.ConfigID != "" {
+		cfg, err := l.scoreConfig(ctx, s.ConfigID)
+		if err != nil {
+			// 无法获取配置时交给服务端校验
+			log.Warnf(ctx, "get score config %s error, skip client-side validation: %s", s.ConfigID, err.Error())
+		} else if err = validateScoreConfig(cfg, s); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateScore(s); err != nil {
+		return nil, err
+	}
+
+	s.ID = l.buildID(&s.ID)
+	if err := l.dispatch(
+		model.IngestionEvent{
+			ID:        l.buildID(nil),
+			Type:      model.IngestionEventTypeScoreCreate,
+			Timestamp: l.now(),
+			Body:      s,
+		},
+		applyDefaults,
+	); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// scoreConfig 获取分数配置，成功结果一直缓存，失败结果缓存 scoreConfigFailureTTL
+func (l *Langfuse) scoreConfig(ctx context.Context, id string) (*model.ScoreConfig, error) {
+	now := l.clock.Now()
+	l.scoreConfigs.mu.RLock()
+	cfg, ok := l.scoreConfigs.configs[id]
+	failure, failed := l.scoreConfigs.failures[id]
+	l.scoreConfigs.mu.RUnlock()
+	if ok {
+		return cfg, nil
+	}
+	if failed && now.Before(failure.until) {
+		return nil, failure.err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, scoreConfigFetchTimeout)
+	defer cancel()
+	res := api.ScoreConfigResponse{}
+	if err := l.client.GetScoreConfig(fetchCtx, &api.GetScoreConfig{ID: id}, &res); err != nil {
+		if ctx.Err() != nil {
+			// 调用方取消，不代表服务端不可用
+			return nil, err
+		}
+		l.scoreConfigs.mu.Lock()
+		if l.scoreConfigs.failures == nil {
+			l.scoreConfigs.failures = make(map[string]scoreConfigFailure)
+		}
+		l.scoreConfigs.failures[id] = scoreConfigFailure{err: err, until: l.clock.Now().Add(scoreConfigFailureTTL)}
+		l.scoreConfigs.mu.Unlock()
+		return nil, err
+	}
+	cfg = &res.ScoreConfig
+
+	l.scoreConfigs.mu.Lock()
+	if l.scoreConfigs.configs == nil {
+		l.scoreConfigs.configs = make(map[string]*model.ScoreConfig)
+	}
+	l.scoreConfigs.configs[id] = cfg
+	delete(l.scoreConfigs.failures, id)
+	l.scoreConfigs.mu.Unlock()
+	return cfg, nil
+}
+
+// validateScore 校验分数与数据类型是否匹配
+func validateScore(s *model.Score) error {
+	switch s.DataType {
+	case model.ScoreDataTypeBoolean:
+		if s.Value != 0 && s.Value != 1 {
+			return fmt.Errorf("%w: boolean score %q must be 0 or 1, got %v", ErrInvalidScore, s.Name, s.Value)
+		}
+	case model.ScoreDataTypeCategorical:
+		if s.StringValue == "" {
+			return fmt.Errorf("%w: categorical score %q requires StringValue", ErrInvalidScore, s.Name)
+		}
+	case "", model.ScoreDataTypeNumeric:
+	default:
+		return fmt.Errorf("%w: unknown data type %q", ErrInvalidScore, s.DataType)
+	}
+	return nil
+}
+
+// validateScoreConfig 按分数配置校验，未设置 DataType 时使用配置中的类型
+func validateScoreConfig(cfg *model.ScoreConfig, s *model.Score) error {
+	if cfg.IsArchived {
+		return fmt.Errorf("%w: score config %s is archived", ErrInvalidScore, cfg.ID)
+	}
+	if s.DataType == "" {
+		s.DataType = cfg.DataType
+	}
+	if s.DataType != cfg.DataType {
+		return fmt.Errorf("%w: data type %s does not match score config %s (%s)", ErrInvalidScore, s.DataType, cfg.ID, cfg.DataType)
+	}
+
+	switch cfg.DataType {
+	case model.ScoreDataTypeNumeric:
+		if cfg.MinValue != nil && s.Value < *cfg.MinValue {
+			return fmt.Errorf("%w: value %v is below minimum %v of score config %s", ErrInvalidScore, s.Value, *cfg.MinValue, cfg.ID)
+		}
+		if cfg.MaxValue != nil && s.Value > *cfg.MaxValue {
+			return fmt.Errorf("%w: value %v is above maximum %v of score config %s", ErrInvalidScore, s.Value, *cfg.MaxValue, cfg.ID)
+		}
+	case model.ScoreDataTypeCategorical:
+		for _, category := range cfg.Categories {
+			if category.Label == s.StringValue {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: value %q is not a category of score config %s", ErrInvalidScore, s.StringValue, cfg.ID)
+	case model.ScoreDataTypeBoolean:
+	}
+	return nil
+}
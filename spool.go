@@ -0,0 +1,97 @@
+package langfuse
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/spool"
+	"github.com/rongbiwei/langfuse-go/model"
+)
+
+// FsyncPolicy spool 刷盘策略
+type FsyncPolicy = spool.FsyncPolicy
+
+const (
+	// FsyncNever 不主动刷盘，交给操作系统
+	FsyncNever = spool.FsyncNever
+	// FsyncInterval 按 SpoolConfig.FsyncInterval 周期刷盘
+	FsyncInterval = spool.FsyncInterval
+	// FsyncAlways 每个事件写入后立即刷盘
+	FsyncAlways = spool.FsyncAlways
+)
+
+// SpoolConfig 磁盘 spool 配置，事件先写入分段文件，发送成功后删除，进程重启时回放未确认的事件
+type SpoolConfig struct {
+	// Dir spool 目录，必填
+	Dir string
+	// MaxSegmentBytes 单个段文件大小上限，默认 8MB
+	MaxSegmentBytes int64
+	// MaxTotalBytes 目录总大小上限，超过后新事件只保存在内存中，默认 512MB
+	MaxTotalBytes int64
+	// Fsync 刷盘策略，默认 FsyncNever
+	Fsync FsyncPolicy
+	// FsyncInterval FsyncInterval 策略下的刷盘周期，默认 1s
+	FsyncInterval time.Duration
+}
+
+// openSpool 打开 spool 并回放上次未确认的事件，失败时退化为纯内存队列
+func (l *Langfuse) openSpool(ctx context.Context, cfg SpoolConfig) {
+	s, records, err := spool.Open(spool.Options{
+		Dir:             cfg.Dir,
+		MaxSegmentBytes: cfg.MaxSegmentBytes,
+		MaxTotalBytes:   cfg.MaxTotalBytes,
+		Fsync:           cfg.Fsync,
+		FsyncInterval:   cfg.FsyncInterval,
+	})
+	if err != nil {
+		log.Errorf(ctx, "open spool error, fallback to memory queue: %s", err.Error())
+		return
+	}
+	l.spool = s
+
+	for _, record := range records {
+		var event model.IngestionEvent
+		if err = json.Unmarshal(record.Data, &event); err != nil {
+			log.Warnf(ctx, "skip corrupted spool record in segment %d: %s", record.Segment, err.Error())
+			s.Ack(record.Segment)
+			continue
+		}
+		l.observer.Dispatch(envelope{event: event, raw: record.Data, segment: record.Segment, seq: l.seq.Add(1)})
+	}
+	if len(records) > 0 {
+		log.Infof(ctx, "replay %d events from spool", len(records))
+	}
+}
+
+// persist 将已编码的事件追加到 spool，写入失败时事件仍然进入内存队列
+func (l *Langfuse) persist(env *envelope) {
+	if l.spool == nil {
+		return
+	}
+
+	segment, err := l.spool.Append(env.raw)
+	if err != nil {
+		log.Warnf(context.Background(), "spool event %s error: %s", env.event.ID, err.Error())
+		return
+	}
+	env.segment = segment
+}
+
+// ackSpool 确认事件已处理完成（发送成功或最终丢弃）
+func (l *Langfuse) ackSpool(env envelope) {
+	if l.spool != nil {
+		l.spool.Ack(env.segment)
+	}
+}
+
+// closeSpool 关闭 spool，未确认的事件保留到下次启动回放
+func (l *Langfuse) closeSpool(ctx context.Context) {
+	if l.spool == nil {
+		return
+	}
+	if err := l.spool.Close(); err != nil {
+		log.Errorf(ctx, "close spool error: %s", err.Error())
+	}
+}
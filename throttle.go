@@ -0,0 +1,62 @@
+package langfuse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rongbiwei/langfuse-go/internal/pkg/api"
+	"github.com/rongbiwei/langfuse-go/internal/pkg/log"
+)
+
+// throttle 发送前等待服务端要求的暂停结束，并按客户端限流获取令牌
+func (l *Langfuse) throttle(ctx context.Context, size int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := l.waitPause(ctx); err != nil {
+		return err
+	}
+	if err := l.requestLimiter.Wait(ctx, 1); err != nil {
+		return err
+	}
+	return l.byteLimiter.Wait(ctx, float64(size))
+}
+
+// waitPause 等待 429 触发的全局暂停结束
+func (l *Langfuse) waitPause(ctx context.Context) error {
+	for {
+		delay := time.Until(time.Unix(0, l.pauseUntil.Load()))
+		if delay <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// pauseOnRateLimit 收到带 Retry-After 的 429 时暂停所有发送协程
+func (l *Langfuse) pauseOnRateLimit(ctx context.Context, err error) {
+	var statusErr *api.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests || statusErr.RetryAfter <= 0 {
+		return
+	}
+
+	until := time.Now().Add(statusErr.RetryAfter).UnixNano()
+	for {
+		current := l.pauseUntil.Load()
+		if current >= until {
+			return
+		}
+		if l.pauseUntil.CompareAndSwap(current, until) {
+			log.Warnf(ctx, "rate limited by langfuse, pause ingestion for %s", statusErr.RetryAfter)
+			return
+		}
+	}
+}